@@ -0,0 +1,71 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	auth "github.com/alancorleto/gator/internal/auth"
+	database "github.com/alancorleto/gator/internal/database"
+)
+
+// middlewareAuth requires an API token that carries scope. On routes with a
+// {name} path segment, the token must also belong to that user. The token's
+// user is passed to the handler.
+func (s *Server) middlewareAuth(scope string, handler func(w http.ResponseWriter, r *http.Request, user database.User)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := s.authenticate(w, r, scope)
+		if !ok {
+			return
+		}
+
+		if name := r.PathValue("name"); name != "" && name != user.Name {
+			respondWithError(w, http.StatusForbidden, fmt.Sprintf("token does not grant access to user '%s'", name))
+			return
+		}
+
+		handler(w, r, user)
+	}
+}
+
+// authenticate resolves the bearer token of the request to its user. When it
+// fails, it writes the error response and returns false.
+func (s *Server) authenticate(w http.ResponseWriter, r *http.Request, scope string) (database.User, bool) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="gator"`)
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+		return database.User{}, false
+	}
+
+	tokenUser, err := s.db.GetUserByAPIToken(r.Context(), auth.HashToken(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="gator", error="invalid_token"`)
+		respondWithError(w, http.StatusUnauthorized, "invalid or expired token")
+		return database.User{}, false
+	}
+	if err != nil {
+		respondWithDatabaseError(w, err, "")
+		return database.User{}, false
+	}
+
+	if !slices.Contains(tokenUser.Scopes, scope) {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="gator", error="insufficient_scope", scope="%s"`, scope))
+		respondWithError(w, http.StatusForbidden, fmt.Sprintf("token is missing the '%s' scope", scope))
+		return database.User{}, false
+	}
+
+	// Failing to record the last use must not fail the request.
+	s.db.MarkAPITokenUsed(r.Context(), tokenUser.TokenID)
+
+	return database.User{
+		ID:           tokenUser.ID,
+		CreatedAt:    tokenUser.CreatedAt,
+		UpdatedAt:    tokenUser.UpdatedAt,
+		Name:         tokenUser.Name,
+		PasswordHash: tokenUser.PasswordHash,
+		Role:         tokenUser.Role,
+	}, true
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	database "github.com/alancorleto/gator/internal/database"
+	"github.com/google/uuid"
+)
+
+func (s *Server) handlerGetFeeds(w http.ResponseWriter, r *http.Request) {
+	feeds, err := s.db.GetFeeds(r.Context())
+	if err != nil {
+		respondWithDatabaseError(w, err, "")
+		return
+	}
+
+	response := make([]feedResponse, 0, len(feeds))
+	for _, feed := range feeds {
+		var ownerName *string
+		if feed.UserName.Valid {
+			ownerName = &feed.UserName.String
+		}
+		response = append(response, feedResponse{
+			ID:        feed.ID,
+			Name:      feed.Name,
+			Url:       feed.Url,
+			OwnerName: ownerName,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// handlerCreateFeed adds a feed owned by the user in the path, who also starts
+// following it, like the addfeed command.
+func (s *Server) handlerCreateFeed(w http.ResponseWriter, r *http.Request, user database.User) {
+	var request struct {
+		Name string `json:"name"`
+		Url  string `json:"url"`
+	}
+	if err := decodeJSON(w, r, &request); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	name := strings.TrimSpace(request.Name)
+	if name == "" {
+		respondWithError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+	if parsedUrl, err := url.Parse(request.Url); err != nil || !parsedUrl.IsAbs() {
+		respondWithError(w, http.StatusBadRequest, "url must be an absolute URL")
+		return
+	}
+	if err := s.fetcher.CheckURL(r.Context(), request.Url); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	feed, err := s.db.CreateFeed(
+		r.Context(),
+		database.CreateFeedParams{
+			ID:        uuid.New(),
+			Name:      name,
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+			Url:       request.Url,
+			UserID:    uuid.NullUUID{UUID: user.ID, Valid: true},
+		},
+	)
+	if err != nil {
+		respondWithDatabaseError(w, err, "")
+		return
+	}
+
+	_, err = s.db.CreateFeedFollow(
+		r.Context(),
+		database.CreateFeedFollowParams{
+			ID:        uuid.New(),
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+			UserID:    user.ID,
+			FeedID:    feed.ID,
+		},
+	)
+	if err != nil {
+		respondWithDatabaseError(w, err, fmt.Sprintf("feed '%s' not found", feed.Url))
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, feedResponse{
+		ID:        feed.ID,
+		Name:      feed.Name,
+		Url:       feed.Url,
+		OwnerName: &user.Name,
+	})
+}
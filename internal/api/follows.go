@@ -0,0 +1,87 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	database "github.com/alancorleto/gator/internal/database"
+	"github.com/google/uuid"
+)
+
+func (s *Server) handlerGetFollows(w http.ResponseWriter, r *http.Request, user database.User) {
+	feedFollows, err := s.db.GetFeedFollowsForUser(r.Context(), user.ID)
+	if err != nil {
+		respondWithDatabaseError(w, err, "")
+		return
+	}
+
+	response := make([]followResponse, 0, len(feedFollows))
+	for _, feedFollow := range feedFollows {
+		response = append(response, followResponse{
+			FeedID:    feedFollow.FeedID,
+			FeedName:  feedFollow.FeedName,
+			CreatedAt: feedFollow.CreatedAt,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func (s *Server) handlerCreateFollow(w http.ResponseWriter, r *http.Request, user database.User) {
+	var request struct {
+		FeedUrl string `json:"feed_url"`
+	}
+	if err := decodeJSON(w, r, &request); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	feed, err := s.db.GetFeedByURL(r.Context(), request.FeedUrl)
+	if err != nil {
+		respondWithDatabaseError(w, err, fmt.Sprintf("feed '%s' not found", request.FeedUrl))
+		return
+	}
+
+	feedFollow, err := s.db.CreateFeedFollow(
+		r.Context(),
+		database.CreateFeedFollowParams{
+			ID:        uuid.New(),
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+			UserID:    user.ID,
+			FeedID:    feed.ID,
+		},
+	)
+	if err != nil {
+		respondWithDatabaseError(w, err, fmt.Sprintf("feed '%s' not found", request.FeedUrl))
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, followResponse{
+		FeedID:    feedFollow.FeedID,
+		FeedName:  feedFollow.FeedName,
+		CreatedAt: feedFollow.CreatedAt,
+	})
+}
+
+func (s *Server) handlerDeleteFollow(w http.ResponseWriter, r *http.Request, user database.User) {
+	feedID, err := uuid.Parse(r.PathValue("feedID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid feed ID")
+		return
+	}
+
+	err = s.db.DeleteFeedFollow(
+		r.Context(),
+		database.DeleteFeedFollowParams{
+			UserID: user.ID,
+			FeedID: feedID,
+		},
+	)
+	if err != nil {
+		respondWithDatabaseError(w, err, "")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	database "github.com/alancorleto/gator/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPostsLimit = 20
+	maxPostsLimit     = 100
+)
+
+// handlerGetPosts lists the posts of the feeds the user follows, newest first.
+// Pagination uses the "limit" and "offset" query parameters; the response
+// includes the offset of the next page, or null on the last one.
+func (s *Server) handlerGetPosts(w http.ResponseWriter, r *http.Request, user database.User) {
+	limit, err := queryInt(r, "limit", defaultPostsLimit)
+	if err != nil || limit < 1 || limit > maxPostsLimit {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxPostsLimit))
+		return
+	}
+	offset, err := queryInt(r, "offset", 0)
+	if err != nil || offset < 0 {
+		respondWithError(w, http.StatusBadRequest, "offset must be a non-negative integer")
+		return
+	}
+
+	posts, err := s.db.GetPostsPageForUser(
+		r.Context(),
+		database.GetPostsPageForUserParams{
+			UserID: user.ID,
+			Limit:  int32(limit),
+			Offset: int32(offset),
+		},
+	)
+	if err != nil {
+		respondWithDatabaseError(w, err, "")
+		return
+	}
+
+	response := postsPageResponse{
+		Posts:  make([]postResponse, 0, len(posts)),
+		Limit:  limit,
+		Offset: offset,
+	}
+	for _, post := range posts {
+		var description *string
+		if post.Description.Valid {
+			description = &post.Description.String
+		}
+		response.Posts = append(response.Posts, postResponse{
+			ID:          post.ID,
+			FeedID:      post.FeedID,
+			Title:       post.Title,
+			Url:         post.Url,
+			Description: description,
+			PublishedAt: post.PublishedAt,
+			Read:        post.Read,
+		})
+	}
+	if len(posts) == limit {
+		nextOffset := offset + limit
+		response.NextOffset = &nextOffset
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func (s *Server) handlerMarkPostRead(w http.ResponseWriter, r *http.Request, user database.User) {
+	postID, err := uuid.Parse(r.PathValue("postID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid post ID")
+		return
+	}
+
+	err = s.db.MarkPostRead(
+		r.Context(),
+		database.MarkPostReadParams{
+			UserID: user.ID,
+			PostID: postID,
+			ReadAt: time.Now(),
+		},
+	)
+	if err != nil {
+		respondWithDatabaseError(w, err, fmt.Sprintf("post '%s' not found", postID))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handlerMarkPostUnread(w http.ResponseWriter, r *http.Request, user database.User) {
+	postID, err := uuid.Parse(r.PathValue("postID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid post ID")
+		return
+	}
+
+	err = s.db.MarkPostUnread(
+		r.Context(),
+		database.MarkPostUnreadParams{
+			UserID: user.ID,
+			PostID: postID,
+		},
+	)
+	if err != nil {
+		respondWithDatabaseError(w, err, "")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// queryInt parses the query parameter key, which must fit in the int32 the
+// queries take.
+func queryInt(r *http.Request, key string, defaultValue int) (int, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	n, err := strconv.ParseInt(value, 10, 32)
+	return int(n), err
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"time"
+
+	database "github.com/alancorleto/gator/internal/database"
+	"github.com/google/uuid"
+)
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+type userResponse struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type feedResponse struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Url       string    `json:"url"`
+	OwnerName *string   `json:"owner_name"`
+}
+
+type followResponse struct {
+	FeedID    uuid.UUID `json:"feed_id"`
+	FeedName  string    `json:"feed_name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type postResponse struct {
+	ID          uuid.UUID `json:"id"`
+	FeedID      uuid.UUID `json:"feed_id"`
+	Title       string    `json:"title"`
+	Url         string    `json:"url"`
+	Description *string   `json:"description"`
+	PublishedAt time.Time `json:"published_at"`
+	Read        bool      `json:"read"`
+}
+
+type postsPageResponse struct {
+	Posts      []postResponse `json:"posts"`
+	Limit      int            `json:"limit"`
+	Offset     int            `json:"offset"`
+	NextOffset *int           `json:"next_offset"`
+}
+
+func newUserResponse(user database.User) userResponse {
+	return userResponse{
+		ID:        user.ID,
+		Name:      user.Name,
+		Role:      user.Role,
+		CreatedAt: user.CreatedAt,
+	}
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	auth "github.com/alancorleto/gator/internal/auth"
+	database "github.com/alancorleto/gator/internal/database"
+	feedfetcher "github.com/alancorleto/gator/internal/feed_fetcher"
+	"github.com/lib/pq"
+)
+
+const maxRequestBodySize = 1 << 20
+
+// Server exposes gator's data as a JSON REST API. It implements http.Handler.
+// Listing users and feeds and registering are public; everything acting on
+// behalf of a user requires one of that user's API tokens.
+type Server struct {
+	db      *database.Queries
+	fetcher *feedfetcher.Fetcher
+	mux     *http.ServeMux
+}
+
+func NewServer(db *database.Queries, fetcher *feedfetcher.Fetcher) *Server {
+	s := &Server{
+		db:      db,
+		fetcher: fetcher,
+		mux:     http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("GET /api/openapi.yaml", handlerOpenAPI)
+
+	s.mux.HandleFunc("GET /api/users", s.handlerGetUsers)
+	s.mux.HandleFunc("POST /api/users", s.handlerCreateUser)
+	s.mux.HandleFunc("GET /api/users/{name}", s.handlerGetUser)
+	s.mux.HandleFunc("GET /api/me", s.middlewareAuth(auth.ScopeRead, handlerGetMe))
+
+	s.mux.HandleFunc("GET /api/feeds", s.handlerGetFeeds)
+	s.mux.HandleFunc("POST /api/users/{name}/feeds", s.middlewareAuth(auth.ScopeWrite, s.handlerCreateFeed))
+
+	s.mux.HandleFunc("GET /api/users/{name}/follows", s.middlewareAuth(auth.ScopeRead, s.handlerGetFollows))
+	s.mux.HandleFunc("POST /api/users/{name}/follows", s.middlewareAuth(auth.ScopeWrite, s.handlerCreateFollow))
+	s.mux.HandleFunc("DELETE /api/users/{name}/follows/{feedID}", s.middlewareAuth(auth.ScopeWrite, s.handlerDeleteFollow))
+
+	s.mux.HandleFunc("GET /api/users/{name}/posts", s.middlewareAuth(auth.ScopeRead, s.handlerGetPosts))
+	s.mux.HandleFunc("PUT /api/users/{name}/posts/{postID}/read", s.middlewareAuth(auth.ScopeWrite, s.handlerMarkPostRead))
+	s.mux.HandleFunc("DELETE /api/users/{name}/posts/{postID}/read", s.middlewareAuth(auth.ScopeWrite, s.handlerMarkPostUnread))
+
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// userFromPath looks up the user named in the request path. When it fails, it
+// writes the error response and returns false.
+func (s *Server) userFromPath(w http.ResponseWriter, r *http.Request) (database.User, bool) {
+	user, err := s.db.GetUser(r.Context(), r.PathValue("name"))
+	if err != nil {
+		respondWithDatabaseError(w, err, fmt.Sprintf("user '%s' not found", r.PathValue("name")))
+		return database.User{}, false
+	}
+	return user, true
+}
+
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("invalid request body: %v", err)
+	}
+	return nil
+}
+
+func respondWithJSON(w http.ResponseWriter, code int, payload any) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error encoding response")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(data)
+}
+
+func respondWithError(w http.ResponseWriter, code int, message string) {
+	respondWithJSON(w, code, errorResponse{Error: message})
+}
+
+// respondWithDatabaseError maps a database error to a status code: missing
+// rows and foreign key violations are 404 Not Found (with notFoundMessage),
+// unique violations 409 Conflict, and anything else 500.
+func respondWithDatabaseError(w http.ResponseWriter, err error, notFoundMessage string) {
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, notFoundMessage)
+		return
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "23503":
+			respondWithError(w, http.StatusNotFound, notFoundMessage)
+			return
+		case "23505":
+			respondWithError(w, http.StatusConflict, "resource already exists")
+			return
+		}
+	}
+
+	respondWithError(w, http.StatusInternalServerError, "internal server error")
+}
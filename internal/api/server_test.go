@@ -0,0 +1,361 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	auth "github.com/alancorleto/gator/internal/auth"
+	database "github.com/alancorleto/gator/internal/database"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const testToken = "gator_test_token"
+
+var (
+	testUserID  = uuid.MustParse("11111111-1111-1111-1111-111111111111")
+	testTokenID = uuid.MustParse("22222222-2222-2222-2222-222222222222")
+	testFeedID  = uuid.MustParse("33333333-3333-3333-3333-333333333333")
+	testTime    = time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+)
+
+// newTestServer serves the API on a mocked database. Expected queries are
+// matched by their sqlc name, such as "GetUser".
+func newTestServer(t *testing.T) (*httptest.Server, sqlmock.Sqlmock) {
+	t.Helper()
+
+	matchName := sqlmock.QueryMatcherFunc(func(expectedName, actualSQL string) error {
+		if !strings.HasPrefix(actualSQL, "-- name: "+expectedName+" ") {
+			return fmt.Errorf("query %q is not %s", strings.SplitN(actualSQL, "\n", 2)[0], expectedName)
+		}
+		return nil
+	})
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(matchName))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(NewServer(database.New(db), nil))
+	t.Cleanup(func() {
+		server.Close()
+		db.Close()
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+	return server, mock
+}
+
+// expectToken makes testToken resolve to the user "alice" with scopes.
+func expectToken(mock sqlmock.Sqlmock, scopes ...string) {
+	mock.ExpectQuery("GetUserByAPIToken").
+		WithArgs(auth.HashToken(testToken)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "name", "password_hash", "role", "token_id", "scopes"}).
+			AddRow(testUserID, testTime, testTime, "alice", "hash", auth.RoleMember, testTokenID, "{"+strings.Join(scopes, ",")+"}"))
+	mock.ExpectExec("MarkAPITokenUsed").
+		WithArgs(testTokenID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func doRequest(t *testing.T, server *httptest.Server, method string, path string, token string, body string) (*http.Response, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(data)
+}
+
+func TestAuthentication(t *testing.T) {
+	t.Run("missing token", func(t *testing.T) {
+		server, _ := newTestServer(t)
+		resp, body := doRequest(t, server, "GET", "/api/me", "", "")
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("status = %d, want %d: %s", resp.StatusCode, http.StatusUnauthorized, body)
+		}
+		if resp.Header.Get("WWW-Authenticate") == "" {
+			t.Error("missing WWW-Authenticate header")
+		}
+	})
+
+	t.Run("unknown token", func(t *testing.T) {
+		server, mock := newTestServer(t)
+		mock.ExpectQuery("GetUserByAPIToken").
+			WithArgs(auth.HashToken(testToken)).
+			WillReturnError(sql.ErrNoRows)
+		resp, body := doRequest(t, server, "GET", "/api/me", testToken, "")
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("status = %d, want %d: %s", resp.StatusCode, http.StatusUnauthorized, body)
+		}
+		if !strings.Contains(resp.Header.Get("WWW-Authenticate"), "invalid_token") {
+			t.Errorf("WWW-Authenticate = %q, want invalid_token", resp.Header.Get("WWW-Authenticate"))
+		}
+	})
+
+	t.Run("missing scope", func(t *testing.T) {
+		server, mock := newTestServer(t)
+		mock.ExpectQuery("GetUserByAPIToken").
+			WithArgs(auth.HashToken(testToken)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "name", "password_hash", "role", "token_id", "scopes"}).
+				AddRow(testUserID, testTime, testTime, "alice", "hash", auth.RoleMember, testTokenID, "{read}"))
+		resp, body := doRequest(t, server, "PUT", "/api/users/alice/posts/"+uuid.NewString()+"/read", testToken, "")
+		if resp.StatusCode != http.StatusForbidden {
+			t.Fatalf("status = %d, want %d: %s", resp.StatusCode, http.StatusForbidden, body)
+		}
+		if !strings.Contains(resp.Header.Get("WWW-Authenticate"), "insufficient_scope") {
+			t.Errorf("WWW-Authenticate = %q, want insufficient_scope", resp.Header.Get("WWW-Authenticate"))
+		}
+	})
+
+	t.Run("token of another user", func(t *testing.T) {
+		server, mock := newTestServer(t)
+		expectToken(mock, auth.ScopeRead)
+		resp, body := doRequest(t, server, "GET", "/api/users/bob/posts", testToken, "")
+		if resp.StatusCode != http.StatusForbidden {
+			t.Fatalf("status = %d, want %d: %s", resp.StatusCode, http.StatusForbidden, body)
+		}
+	})
+
+	t.Run("valid token", func(t *testing.T) {
+		server, mock := newTestServer(t)
+		expectToken(mock, auth.ScopeRead)
+		resp, body := doRequest(t, server, "GET", "/api/me", testToken, "")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status = %d, want %d: %s", resp.StatusCode, http.StatusOK, body)
+		}
+		var user userResponse
+		if err := json.Unmarshal([]byte(body), &user); err != nil {
+			t.Fatal(err)
+		}
+		if user.ID != testUserID || user.Name != "alice" {
+			t.Errorf("user = %+v, want alice", user)
+		}
+	})
+}
+
+func TestStatusCodes(t *testing.T) {
+	t.Run("unknown user", func(t *testing.T) {
+		server, mock := newTestServer(t)
+		mock.ExpectQuery("GetUser").WithArgs("nobody").WillReturnError(sql.ErrNoRows)
+		resp, body := doRequest(t, server, "GET", "/api/users/nobody", "", "")
+		if resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("status = %d, want %d: %s", resp.StatusCode, http.StatusNotFound, body)
+		}
+	})
+
+	t.Run("invalid request body", func(t *testing.T) {
+		server, _ := newTestServer(t)
+		resp, body := doRequest(t, server, "POST", "/api/users", "", `{"name": "alice", "unknown": true}`)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("status = %d, want %d: %s", resp.StatusCode, http.StatusBadRequest, body)
+		}
+	})
+
+	t.Run("database failure", func(t *testing.T) {
+		server, mock := newTestServer(t)
+		mock.ExpectQuery("GetUser").WithArgs("alice").WillReturnError(fmt.Errorf("connection lost"))
+		resp, body := doRequest(t, server, "GET", "/api/users/alice", "", "")
+		if resp.StatusCode != http.StatusInternalServerError {
+			t.Fatalf("status = %d, want %d: %s", resp.StatusCode, http.StatusInternalServerError, body)
+		}
+		if strings.Contains(body, "connection lost") {
+			t.Errorf("response leaks the database error: %s", body)
+		}
+	})
+
+	t.Run("unknown route", func(t *testing.T) {
+		server, _ := newTestServer(t)
+		resp, _ := doRequest(t, server, "GET", "/api/nothing", "", "")
+		if resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusNotFound)
+		}
+	})
+}
+
+func postRows(count int, read bool) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "created_at", "updated_at", "title", "url", "description", "published_at", "feed_id", "original_description", "read"})
+	for i := range count {
+		rows.AddRow(uuid.New(), testTime, testTime, fmt.Sprintf("Post %d", i), fmt.Sprintf("http://example.com/%d", i), nil, testTime, testFeedID, nil, read)
+	}
+	return rows
+}
+
+func TestGetPostsPagination(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		wantLimit      int
+		wantOffset     int
+		rows           int
+		wantNextOffset *int
+	}{
+		{name: "defaults", query: "", wantLimit: defaultPostsLimit, wantOffset: 0, rows: 3, wantNextOffset: nil},
+		{name: "full page", query: "?limit=2&offset=4", wantLimit: 2, wantOffset: 4, rows: 2, wantNextOffset: new(6)},
+		{name: "last page", query: "?limit=2&offset=6", wantLimit: 2, wantOffset: 6, rows: 1, wantNextOffset: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, mock := newTestServer(t)
+			expectToken(mock, auth.ScopeRead)
+			mock.ExpectQuery("GetPostsPageForUser").
+				WithArgs(testUserID, test.wantLimit, test.wantOffset).
+				WillReturnRows(postRows(test.rows, false))
+
+			resp, body := doRequest(t, server, "GET", "/api/users/alice/posts"+test.query, testToken, "")
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d, want %d: %s", resp.StatusCode, http.StatusOK, body)
+			}
+			var page postsPageResponse
+			if err := json.Unmarshal([]byte(body), &page); err != nil {
+				t.Fatal(err)
+			}
+			if len(page.Posts) != test.rows || page.Limit != test.wantLimit || page.Offset != test.wantOffset {
+				t.Errorf("page has %d posts, limit %d, offset %d; want %d, %d, %d", len(page.Posts), page.Limit, page.Offset, test.rows, test.wantLimit, test.wantOffset)
+			}
+			switch {
+			case test.wantNextOffset == nil && page.NextOffset != nil:
+				t.Errorf("next_offset = %d, want null", *page.NextOffset)
+			case test.wantNextOffset != nil && (page.NextOffset == nil || *page.NextOffset != *test.wantNextOffset):
+				t.Errorf("next_offset = %v, want %d", page.NextOffset, *test.wantNextOffset)
+			}
+		})
+	}
+
+	for _, query := range []string{"?limit=0", "?limit=101", "?limit=x", "?limit=4294967297", "?offset=-1", "?offset=4294967296", "?offset=2147483648"} {
+		t.Run("invalid "+query, func(t *testing.T) {
+			server, mock := newTestServer(t)
+			expectToken(mock, auth.ScopeRead)
+			resp, body := doRequest(t, server, "GET", "/api/users/alice/posts"+query, testToken, "")
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d: %s", resp.StatusCode, http.StatusBadRequest, body)
+			}
+		})
+	}
+}
+
+func TestReadState(t *testing.T) {
+	postID := uuid.New()
+	path := "/api/users/alice/posts/" + postID.String() + "/read"
+
+	t.Run("posts report their read state", func(t *testing.T) {
+		server, mock := newTestServer(t)
+		expectToken(mock, auth.ScopeRead)
+		mock.ExpectQuery("GetPostsPageForUser").WillReturnRows(postRows(1, true))
+		_, body := doRequest(t, server, "GET", "/api/users/alice/posts", testToken, "")
+		var page postsPageResponse
+		if err := json.Unmarshal([]byte(body), &page); err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Posts) != 1 || !page.Posts[0].Read {
+			t.Errorf("posts = %+v, want one read post", page.Posts)
+		}
+	})
+
+	t.Run("mark read", func(t *testing.T) {
+		server, mock := newTestServer(t)
+		expectToken(mock, auth.ScopeWrite)
+		mock.ExpectExec("MarkPostRead").
+			WithArgs(testUserID, postID, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		resp, body := doRequest(t, server, "PUT", path, testToken, "")
+		if resp.StatusCode != http.StatusNoContent {
+			t.Fatalf("status = %d, want %d: %s", resp.StatusCode, http.StatusNoContent, body)
+		}
+	})
+
+	t.Run("mark unknown post read", func(t *testing.T) {
+		server, mock := newTestServer(t)
+		expectToken(mock, auth.ScopeWrite)
+		mock.ExpectExec("MarkPostRead").
+			WithArgs(testUserID, postID, sqlmock.AnyArg()).
+			WillReturnError(&pq.Error{Code: "23503"})
+		resp, body := doRequest(t, server, "PUT", path, testToken, "")
+		if resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("status = %d, want %d: %s", resp.StatusCode, http.StatusNotFound, body)
+		}
+	})
+
+	t.Run("mark unread", func(t *testing.T) {
+		server, mock := newTestServer(t)
+		expectToken(mock, auth.ScopeWrite)
+		mock.ExpectExec("MarkPostUnread").
+			WithArgs(testUserID, postID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		resp, body := doRequest(t, server, "DELETE", path, testToken, "")
+		if resp.StatusCode != http.StatusNoContent {
+			t.Fatalf("status = %d, want %d: %s", resp.StatusCode, http.StatusNoContent, body)
+		}
+	})
+
+	t.Run("invalid post ID", func(t *testing.T) {
+		server, mock := newTestServer(t)
+		expectToken(mock, auth.ScopeWrite)
+		resp, body := doRequest(t, server, "PUT", "/api/users/alice/posts/not-a-uuid/read", testToken, "")
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("status = %d, want %d: %s", resp.StatusCode, http.StatusBadRequest, body)
+		}
+	})
+}
+
+func TestCreateUser(t *testing.T) {
+	userRows := func(role string) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "created_at", "updated_at", "name", "password_hash", "role"}).
+			AddRow(testUserID, testTime, testTime, "alice", "hash", role)
+	}
+	tests := []struct {
+		name       string
+		admins     int64
+		wantRole   string
+		createErr  error
+		wantStatus int
+	}{
+		{name: "first user is admin", admins: 0, wantRole: auth.RoleAdmin, wantStatus: http.StatusCreated},
+		{name: "later users are members", admins: 1, wantRole: auth.RoleMember, wantStatus: http.StatusCreated},
+		{name: "name taken", admins: 1, wantRole: auth.RoleMember, createErr: &pq.Error{Code: "23505"}, wantStatus: http.StatusConflict},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, mock := newTestServer(t)
+			mock.ExpectBegin()
+			mock.ExpectExec("LockUsers").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectQuery("CountAdmins").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(test.admins))
+			create := mock.ExpectQuery("CreateUser").
+				WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "alice", sqlmock.AnyArg(), test.wantRole)
+			if test.createErr != nil {
+				create.WillReturnError(test.createErr)
+				mock.ExpectRollback()
+			} else {
+				create.WillReturnRows(userRows(test.wantRole))
+				mock.ExpectCommit()
+			}
+
+			resp, body := doRequest(t, server, "POST", "/api/users", "", `{"name": "alice", "password": "secret password"}`)
+			if resp.StatusCode != test.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", resp.StatusCode, test.wantStatus, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+	"time"
+
+	auth "github.com/alancorleto/gator/internal/auth"
+	database "github.com/alancorleto/gator/internal/database"
+	"github.com/google/uuid"
+)
+
+func (s *Server) handlerGetUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := s.db.ListUsers(r.Context())
+	if err != nil {
+		respondWithDatabaseError(w, err, "")
+		return
+	}
+
+	response := make([]userResponse, 0, len(users))
+	for _, user := range users {
+		response = append(response, newUserResponse(user))
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func (s *Server) handlerGetUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.userFromPath(w, r)
+	if !ok {
+		return
+	}
+	respondWithJSON(w, http.StatusOK, newUserResponse(user))
+}
+
+func (s *Server) handlerCreateUser(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Name     string `json:"name"`
+		Password string `json:"password"`
+	}
+	if err := decodeJSON(w, r, &request); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	name := strings.TrimSpace(request.Name)
+	if name == "" {
+		respondWithError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+
+	passwordHash, err := auth.HashPassword(request.Password)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// The first user becomes the admin, as with the register command, which
+	// also explains the lock.
+	var user database.User
+	err = s.db.InTx(r.Context(), func(db *database.Queries) error {
+		if err := db.LockUsers(r.Context()); err != nil {
+			return err
+		}
+		adminCount, err := db.CountAdmins(r.Context())
+		if err != nil {
+			return err
+		}
+		role := auth.RoleMember
+		if adminCount == 0 {
+			role = auth.RoleAdmin
+		}
+
+		user, err = db.CreateUser(
+			r.Context(),
+			database.CreateUserParams{
+				ID:           uuid.New(),
+				Name:         name,
+				CreatedAt:    time.Now(),
+				UpdatedAt:    time.Now(),
+				PasswordHash: sql.NullString{String: passwordHash, Valid: true},
+				Role:         role,
+			},
+		)
+		return err
+	})
+	if err != nil {
+		respondWithDatabaseError(w, err, "")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, newUserResponse(user))
+}
+
+func handlerGetMe(w http.ResponseWriter, r *http.Request, user database.User) {
+	respondWithJSON(w, http.StatusOK, newUserResponse(user))
+}
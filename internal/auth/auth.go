@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const MinPasswordLength = 8
+
+func HashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength {
+		return "", fmt.Errorf("password must be at least %d characters long", MinPasswordLength)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func CheckPasswordHash(password, hash string) error {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+}
+
+// MakeToken returns a random 256-bit token encoded as hex.
+func MakeToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
+
+// HashToken returns the SHA-256 digest of a token. Tokens are random and long
+// enough that a fast hash is sufficient, and only the digest is stored so a
+// database leak does not expose usable tokens.
+func HashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+const (
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
+const (
+	APITokenPrefix = "gator_"
+
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+)
+
+var Scopes = []string{ScopeRead, ScopeWrite}
+
+// MakeAPIToken returns a new random API token. The prefix makes gator tokens
+// easy to recognize, for example by secret scanners.
+func MakeAPIToken() (string, error) {
+	token, err := MakeToken()
+	if err != nil {
+		return "", err
+	}
+	return APITokenPrefix + token, nil
+}
+
+// GetBearerToken extracts the token from an "Authorization: Bearer <token>"
+// header.
+func GetBearerToken(headers http.Header) (string, error) {
+	authorization := headers.Get("Authorization")
+	if authorization == "" {
+		return "", fmt.Errorf("missing authorization header")
+	}
+
+	scheme, token, found := strings.Cut(authorization, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return "", fmt.Errorf("malformed authorization header")
+	}
+	return strings.TrimSpace(token), nil
+}
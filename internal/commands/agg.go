@@ -0,0 +1,326 @@
+package commands
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	daemon "github.com/alancorleto/gator/internal/daemon"
+	feedscraper "github.com/alancorleto/gator/internal/feed_scraper"
+	metrics "github.com/alancorleto/gator/internal/metrics"
+	state "github.com/alancorleto/gator/internal/state"
+)
+
+const (
+	pidFileName       = ".gator_agg.pid"
+	defaultStatusAddr = "localhost:8081"
+	// healthGrace is how much longer than two rounds agg may go without
+	// ending one before /healthz reports it stuck.
+	healthGrace = 5 * time.Minute
+)
+
+func handlerAgg(ctx context.Context, state *state.State, cmd Command) error {
+	flags := flag.NewFlagSet("agg", flag.ContinueOnError)
+	workers := flags.Int("workers", 1, "number of feeds to scrape at the same time")
+	once := flags.Bool("once", false, "scrape every due feed once, then exit")
+	daemonMode := flags.Bool("daemon", false, "lock a PID file and serve health and status pages")
+	pidFile := flags.String("pid-file", "", "PID file of the daemon (default ~/"+pidFileName+")")
+	statusAddr := flags.String("status-addr", defaultStatusAddr, "address of the health and status pages of the daemon")
+	metricsAddr := flags.String("metrics-addr", "", "address to serve Prometheus metrics on, also served by the daemon status pages")
+	arguments, err := parseArguments(flags, cmd.Arguments)
+	if err != nil {
+		return err
+	}
+	if *workers < 1 {
+		return fmt.Errorf("--workers must be at least 1")
+	}
+	if *daemonMode && *once {
+		return fmt.Errorf("--daemon and --once cannot be used together")
+	}
+	// agg --once exits before anything could scrape its metrics.
+	if *metricsAddr != "" && *once {
+		return fmt.Errorf("--metrics-addr and --once cannot be used together")
+	}
+	daemonFlagSet := false
+	flags.Visit(func(f *flag.Flag) {
+		daemonFlagSet = daemonFlagSet || f.Name == "pid-file" || f.Name == "status-addr"
+	})
+	if daemonFlagSet && !*daemonMode {
+		return fmt.Errorf("--pid-file and --status-addr require --daemon")
+	}
+
+	timeBetweenRequests := 1 * time.Minute
+	if len(arguments) >= 1 {
+		if *once {
+			return fmt.Errorf("agg --once does not take a frequency")
+		}
+		timeArgument, err := time.ParseDuration(arguments[0])
+		if err != nil {
+			return fmt.Errorf("error parsing first argument (time between requests): %v", err)
+		}
+		timeBetweenRequests = timeArgument
+	}
+
+	started := time.Now()
+	summary := &aggSummary{startedAt: started}
+
+	// Scrapes in progress when agg is interrupted get shutdownTimeout to
+	// finish, so that no feed is left half written.
+	scrapeCtx, cancelScrapes := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelScrapes()
+	stopDraining := context.AfterFunc(ctx, func() {
+		state.Logger.Info("interrupted, waiting for the feeds in progress")
+		time.AfterFunc(shutdownTimeout, cancelScrapes)
+	})
+	defer stopDraining()
+
+	if *once {
+		state.Logger.Info("collecting every due feed once", "workers", *workers)
+
+		// Feeds are marked fetched with the database's clock, which may not
+		// agree with ours.
+		dbStarted, err := state.Db.GetDatabaseTime(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get the time of the database: %v", err)
+		}
+		fetchedBefore := sql.NullTime{Time: dbStarted, Valid: true}
+
+		var wg sync.WaitGroup
+		for range *workers {
+			wg.Go(func() {
+				// Feeds scraped since agg started are no longer due, so each
+				// worker stops once every feed had its turn.
+				for ctx.Err() == nil {
+					if !scrapeNextFeed(scrapeCtx, state, summary, fetchedBefore) {
+						return
+					}
+				}
+			})
+		}
+		wg.Wait()
+
+		fmt.Printf("Done in %v: %s.\n", time.Since(started).Round(time.Second), summary)
+		if summary.failed > 0 {
+			return fmt.Errorf("%d feed(s) failed", summary.failed)
+		}
+		return nil
+	}
+
+	if *metricsAddr != "" {
+		stopMetrics, err := serveMetrics(state, *metricsAddr)
+		if err != nil {
+			return err
+		}
+		defer stopMetrics()
+	}
+
+	if *daemonMode {
+		stopDaemon, err := startDaemon(state, summary, *pidFile, *statusAddr, 2*timeBetweenRequests+healthGrace)
+		if err != nil {
+			return err
+		}
+		defer stopDaemon()
+	}
+
+	state.Logger.Info("collecting feeds", "every", timeBetweenRequests, "workers", *workers)
+
+	ticker := time.NewTicker(timeBetweenRequests)
+	defer ticker.Stop()
+	for {
+		var wg sync.WaitGroup
+		for range *workers {
+			wg.Go(func() {
+				scrapeNextFeed(scrapeCtx, state, summary, sql.NullTime{})
+			})
+		}
+		wg.Wait()
+		summary.roundDone()
+		if *daemonMode {
+			daemon.Notify("STATUS=" + summary.String())
+		}
+
+		select {
+		case <-ctx.Done():
+			fmt.Printf("Stopped after %v: %s.\n", time.Since(started).Round(time.Second), summary)
+			return nil
+		case tick := <-ticker.C:
+			// A tick is kept while a round runs late, so the next round
+			// starts right after it, as late as it was.
+			metrics.SchedulerLag.Observe(time.Since(tick).Seconds())
+		}
+	}
+}
+
+func handlerRefresh(ctx context.Context, state *state.State, cmd Command) error {
+	if len(cmd.Arguments) < 1 {
+		return fmt.Errorf("feed url argument is required for refresh command")
+	}
+
+	feed, err := state.Db.GetFeedByURL(ctx, cmd.Arguments[0])
+	if err != nil {
+		return fmt.Errorf("feed '%s' does not exist", cmd.Arguments[0])
+	}
+
+	// Marking the feed fetched keeps agg from fetching it again right after.
+	err = state.Db.MarkFeedFetched(ctx, feed.ID)
+	if err != nil {
+		return fmt.Errorf("failed to mark feed %s as fetched: %v", feed.Name, err)
+	}
+
+	// Like agg, let an interrupted refresh finish writing the feed.
+	result, err := feedscraper.ScrapeFeed(context.WithoutCancel(ctx), state.Db, state.Fetcher, state.Logger, feed)
+	if err != nil {
+		return fmt.Errorf("failed to refresh %s: %v", feed.Name, err)
+	}
+
+	if result.URLChange != nil {
+		fmt.Println(describeURLChange(*result.URLChange))
+	}
+	fmt.Printf("Feed '%s' refreshed: %d new post(s).\n", result.FeedName, result.NewPosts)
+	return nil
+}
+
+// startDaemon locks the PID file, serves the health and status pages and
+// tells the service manager agg is ready. The returned function undoes it.
+func startDaemon(state *state.State, summary *aggSummary, pidFilePath string, statusAddr string, staleAfter time.Duration) (func(), error) {
+	if pidFilePath == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		pidFilePath = filepath.Join(homeDir, pidFileName)
+	}
+	pidFile, err := daemon.AcquirePIDFile(pidFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Listening before reporting ready surfaces an address already in use.
+	listener, err := net.Listen("tcp", statusAddr)
+	if err != nil {
+		pidFile.Release()
+		return nil, fmt.Errorf("failed to serve status pages: %v", err)
+	}
+	server := &http.Server{
+		Handler:           daemon.NewStatusServer(state.Db, summary.progress, staleAfter),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go server.Serve(listener)
+
+	state.Logger.Info("serving health and status pages", "url", "http://"+listener.Addr().String())
+	daemon.Notify("READY=1")
+
+	return func() {
+		daemon.Notify("STOPPING=1")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+		if err := pidFile.Release(); err != nil {
+			state.Logger.Error("failed to remove PID file", "path", pidFilePath, "error", err)
+		}
+	}, nil
+}
+
+// serveMetrics serves the Prometheus metrics on addr. The returned function
+// stops serving them.
+func serveMetrics(state *state.State, addr string) (func(), error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serve metrics: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", metrics.Handler())
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go server.Serve(listener)
+
+	state.Logger.Info("serving metrics", "url", "http://"+listener.Addr().String()+"/metrics")
+
+	return func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}, nil
+}
+
+// aggSummary counts what agg did, for the report printed when it stops and
+// the status pages of the daemon.
+type aggSummary struct {
+	mu              sync.Mutex
+	startedAt       time.Time
+	lastRoundAt     time.Time
+	lastSuccessAt   time.Time
+	lastSuccessFeed string
+	scraped         int
+	failed          int
+	newPosts        int
+	retries         int
+}
+
+func (summary *aggSummary) add(result feedscraper.ScrapeResult, err error) {
+	summary.mu.Lock()
+	defer summary.mu.Unlock()
+	if err != nil {
+		summary.failed++
+	} else {
+		summary.scraped++
+		summary.lastSuccessAt = time.Now()
+		summary.lastSuccessFeed = result.FeedName
+	}
+	summary.newPosts += result.NewPosts
+	summary.retries += result.Retries
+}
+
+func (summary *aggSummary) roundDone() {
+	summary.mu.Lock()
+	defer summary.mu.Unlock()
+	summary.lastRoundAt = time.Now()
+}
+
+func (summary *aggSummary) progress() daemon.Progress {
+	summary.mu.Lock()
+	defer summary.mu.Unlock()
+	return daemon.Progress{
+		StartedAt:       summary.startedAt,
+		LastRoundAt:     summary.lastRoundAt,
+		LastSuccessAt:   summary.lastSuccessAt,
+		LastSuccessFeed: summary.lastSuccessFeed,
+		Scraped:         summary.scraped,
+		Failed:          summary.failed,
+		NewPosts:        summary.newPosts,
+		Retries:         summary.retries,
+	}
+}
+
+func (summary *aggSummary) String() string {
+	summary.mu.Lock()
+	defer summary.mu.Unlock()
+	return fmt.Sprintf("%d feed(s) scraped, %d failed, %d new post(s), %d retry(ies)", summary.scraped, summary.failed, summary.newPosts, summary.retries)
+}
+
+// scrapeNextFeed scrapes one feed for agg and counts how it went; the scraper
+// logs the details. It returns false if no feed was due or none could be
+// picked.
+func scrapeNextFeed(ctx context.Context, state *state.State, summary *aggSummary, fetchedBefore sql.NullTime) bool {
+	result, err := feedscraper.ScrapeNextFeed(ctx, state.Db, state.Fetcher, state.Logger, fetchedBefore)
+	if errors.Is(err, feedscraper.ErrNoFeedDue) {
+		state.Logger.Debug("no feed is due")
+		return false
+	}
+	summary.add(result, err)
+	if result.FeedName == "" {
+		state.Logger.Error("failed to pick the next feed", "error", err)
+		return false
+	}
+	return true
+}
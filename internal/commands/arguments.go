@@ -0,0 +1,21 @@
+package commands
+
+import "flag"
+
+// parseArguments parses flags wherever they appear among the arguments, so
+// that "rmfeed <url> --yes" works as well as "rmfeed --yes <url>", and
+// returns the remaining positional arguments.
+func parseArguments(flags *flag.FlagSet, arguments []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(arguments); err != nil {
+			return nil, err
+		}
+		arguments = flags.Args()
+		if len(arguments) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, arguments[0])
+		arguments = arguments[1:]
+	}
+}
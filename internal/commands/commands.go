@@ -2,14 +2,29 @@ package commands
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+	"net/http"
+	"os"
 	"strconv"
 	"time"
 
+	api "github.com/alancorleto/gator/internal/api"
+	auth "github.com/alancorleto/gator/internal/auth"
 	database "github.com/alancorleto/gator/internal/database"
-	feedscraper "github.com/alancorleto/gator/internal/feed_scraper"
+	htmlrenderer "github.com/alancorleto/gator/internal/html_renderer"
 	state "github.com/alancorleto/gator/internal/state"
+	tui "github.com/alancorleto/gator/internal/tui"
 	"github.com/google/uuid"
+	"golang.org/x/term"
+)
+
+const (
+	defaultOutputWidth = 80
+	defaultServeAddr   = ":8080"
+	// shutdownTimeout is how long serve and agg wait for work in progress
+	// after being interrupted.
+	shutdownTimeout = 30 * time.Second
 )
 
 type Command struct {
@@ -18,63 +33,103 @@ type Command struct {
 }
 
 type Commands struct {
-	CommandsMap map[string]func(*state.State, Command) error
+	CommandsMap map[string]func(context.Context, *state.State, Command) error
 }
 
 func InitializeCommands() *Commands {
 	cmds := &Commands{
-		CommandsMap: make(map[string]func(*state.State, Command) error),
+		CommandsMap: make(map[string]func(context.Context, *state.State, Command) error),
 	}
 
 	cmds.register("login", handlerLogin)
 	cmds.register("register", handlerRegister)
-	cmds.register("reset", handlerReset)
+	cmds.register("logout", middleWareLoggedIn(handlerLogout))
+	cmds.register("passwd", middleWareLoggedIn(handlerPasswd))
+	cmds.register("renameuser", middleWareLoggedIn(handlerRenameUser))
+	cmds.register("deleteuser", middleWareLoggedIn(handlerDeleteUser))
+	cmds.register("reset", middleWareAdmin(handlerReset))
 	cmds.register("users", handlerUsers)
+	cmds.register("promote", middleWareAdmin(handlerPromote))
+	cmds.register("demote", middleWareAdmin(handlerDemote))
+	cmds.register("setpassword", middleWareAdmin(handlerSetPassword))
 	cmds.register("agg", handlerAgg)
+	cmds.register("refresh", handlerRefresh)
 	cmds.register("addfeed", middleWareLoggedIn(handlerAddFeed))
 	cmds.register("feeds", handlerFeeds)
+	cmds.register("rmfeed", middleWareLoggedIn(handlerRemoveFeed))
+	cmds.register("renamefeed", middleWareLoggedIn(handlerRenameFeed))
+	cmds.register("setfeedurl", middleWareLoggedIn(handlerSetFeedURL))
+	cmds.register("transferfeed", middleWareLoggedIn(handlerTransferFeed))
 	cmds.register("follow", middleWareLoggedIn(handlerFollow))
 	cmds.register("following", middleWareLoggedIn(handlerFollowing))
 	cmds.register("unfollow", middleWareLoggedIn(handlerUnfollow))
 	cmds.register("browse", middleWareLoggedIn(handlerBrowse))
+	cmds.register("shell", cmds.handlerShell)
+	cmds.register("tui", middleWareLoggedIn(handlerTUI))
+	cmds.register("serve", handlerServe)
+	cmds.register("token", middleWareLoggedIn(handlerToken))
 
 	return cmds
 }
 
-func (c *Commands) Run(s *state.State, cmd Command) error {
+func (c *Commands) Run(ctx context.Context, s *state.State, cmd Command) error {
 	if handler, exists := c.CommandsMap[cmd.Name]; exists {
-		return handler(s, cmd)
+		return handler(ctx, s, cmd)
 	}
 	return fmt.Errorf("unknown command: %s", cmd.Name)
 }
 
-func (c *Commands) register(name string, handler func(*state.State, Command) error) {
+func (c *Commands) register(name string, handler func(context.Context, *state.State, Command) error) {
 	c.CommandsMap[name] = handler
 }
 
-func middleWareLoggedIn(handler func(state *state.State, cmd Command, user database.User) error) func(state *state.State, cmd Command) error {
-	return func(state *state.State, cmd Command) error {
-		currentUserName := state.Config.CurrentUserName
-		user, err := state.Db.GetUser(context.Background(), currentUserName)
+func middleWareLoggedIn(handler func(ctx context.Context, state *state.State, cmd Command, user database.User) error) func(ctx context.Context, state *state.State, cmd Command) error {
+	return func(ctx context.Context, state *state.State, cmd Command) error {
+		user, err := currentUser(ctx, state)
 		if err != nil {
 			return err
 		}
-		return handler(state, cmd, user)
+		return handler(ctx, state, cmd, user)
 	}
 }
 
-func handlerLogin(state *state.State, cmd Command) error {
+func middleWareAdmin(handler func(ctx context.Context, state *state.State, cmd Command, user database.User) error) func(ctx context.Context, state *state.State, cmd Command) error {
+	return middleWareLoggedIn(func(ctx context.Context, state *state.State, cmd Command, user database.User) error {
+		if user.Role != auth.RoleAdmin {
+			return fmt.Errorf("command '%s' is restricted to admins", cmd.Name)
+		}
+		return handler(ctx, state, cmd, user)
+	})
+}
+
+func handlerLogin(ctx context.Context, state *state.State, cmd Command) error {
 	if len(cmd.Arguments) < 1 {
 		return fmt.Errorf("username argument is required for login command")
 	}
 
 	userName := cmd.Arguments[0]
 
-	if _, err := state.Db.GetUser(context.Background(), userName); err != nil {
+	user, err := state.Db.GetUser(ctx, userName)
+	if err != nil {
 		return fmt.Errorf("user '%s' does not exist", userName)
 	}
 
-	err := state.Config.SetUser(userName)
+	// Users created before passwords were introduced cannot log in until an
+	// admin sets a password for them; letting them choose one here would let
+	// anybody take their account over.
+	if !user.PasswordHash.Valid {
+		return fmt.Errorf("user '%s' has no password yet, ask an admin to set one with 'gator setpassword %s'", userName, userName)
+	}
+
+	password, err := readPassword("Password: ")
+	if err != nil {
+		return err
+	}
+	if err := auth.CheckPasswordHash(password, user.PasswordHash.String); err != nil {
+		return fmt.Errorf("invalid user name or password")
+	}
+
+	err = startSession(ctx, state, user)
 	if err != nil {
 		return err
 	}
@@ -83,48 +138,163 @@ func handlerLogin(state *state.State, cmd Command) error {
 	return nil
 }
 
-func handlerRegister(state *state.State, cmd Command) error {
+func handlerRegister(ctx context.Context, state *state.State, cmd Command) error {
 	if len(cmd.Arguments) < 1 {
 		return fmt.Errorf("username argument is required for register command")
 	}
 
 	userName := cmd.Arguments[0]
 
-	if _, err := state.Db.GetUser(context.Background(), userName); err == nil {
+	if _, err := state.Db.GetUser(ctx, userName); err == nil {
 		return fmt.Errorf("user '%s' already exists", userName)
 	}
 
-	state.Db.CreateUser(
-		context.Background(),
-		database.CreateUserParams{
-			ID:        uuid.New(),
-			Name:      userName,
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
+	passwordHash, err := readNewPassword()
+	if err != nil {
+		return err
+	}
+
+	// The first user becomes the admin so that somebody can promote others.
+	// The users table stays locked from the count to the insert, so that two
+	// users registering at once cannot both become admins.
+	var user database.User
+	err = state.Db.InTx(ctx, func(db *database.Queries) error {
+		if err := db.LockUsers(ctx); err != nil {
+			return fmt.Errorf("failed to lock users: %v", err)
+		}
+		adminCount, err := db.CountAdmins(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to count admins: %v", err)
+		}
+		role := auth.RoleMember
+		if adminCount == 0 {
+			role = auth.RoleAdmin
+		}
+
+		user, err = db.CreateUser(
+			ctx,
+			database.CreateUserParams{
+				ID:           uuid.New(),
+				Name:         userName,
+				CreatedAt:    time.Now(),
+				UpdatedAt:    time.Now(),
+				PasswordHash: sql.NullString{String: passwordHash, Valid: true},
+				Role:         role,
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create user: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = startSession(ctx, state, user)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("User '%s' registered successfully as %s.\n", userName, user.Role)
+	return nil
+}
+
+func handlerPasswd(ctx context.Context, state *state.State, cmd Command, user database.User) error {
+	if user.PasswordHash.Valid {
+		password, err := readPassword("Current password: ")
+		if err != nil {
+			return err
+		}
+		if err := auth.CheckPasswordHash(password, user.PasswordHash.String); err != nil {
+			return fmt.Errorf("current password is incorrect")
+		}
+	}
+
+	passwordHash, err := readNewPassword()
+	if err != nil {
+		return err
+	}
+
+	err = state.Db.UpdateUserPassword(
+		ctx,
+		database.UpdateUserPasswordParams{
+			ID:           user.ID,
+			PasswordHash: sql.NullString{String: passwordHash, Valid: true},
 		},
 	)
+	if err != nil {
+		return fmt.Errorf("failed to update password: %v", err)
+	}
+
+	// Changing the password signs out every other session.
+	err = state.Db.DeleteSessionsForUser(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to end existing sessions: %v", err)
+	}
+	err = startSession(ctx, state, user)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Password for '%s' changed successfully.\n", user.Name)
+	return nil
+}
+
+// handlerSetPassword lets an admin set the password of another user, such as
+// one created before passwords were introduced or one who forgot theirs. The
+// sessions of that user end.
+func handlerSetPassword(ctx context.Context, state *state.State, cmd Command, user database.User) error {
+	if len(cmd.Arguments) < 1 {
+		return fmt.Errorf("username argument is required for setpassword command")
+	}
+
+	target, err := state.Db.GetUser(ctx, cmd.Arguments[0])
+	if err != nil {
+		return fmt.Errorf("user '%s' does not exist", cmd.Arguments[0])
+	}
+	if target.ID == user.ID {
+		return fmt.Errorf("use 'gator passwd' to change your own password")
+	}
 
-	err := state.Config.SetUser(userName)
+	fmt.Printf("Choose a new password for '%s'.\n", target.Name)
+	passwordHash, err := readNewPassword()
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("User '%s' registered successfully.\n", userName)
+	err = state.Db.UpdateUserPassword(
+		ctx,
+		database.UpdateUserPasswordParams{
+			ID:           target.ID,
+			PasswordHash: sql.NullString{String: passwordHash, Valid: true},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set password: %v", err)
+	}
+
+	err = state.Db.DeleteSessionsForUser(ctx, target.ID)
+	if err != nil {
+		return fmt.Errorf("failed to end sessions of '%s': %v", target.Name, err)
+	}
+
+	fmt.Printf("Password for '%s' set successfully.\n", target.Name)
 	return nil
 }
 
-func handlerReset(state *state.State, cmd Command) error {
-	err := state.Db.ResetUsers(context.Background())
+func handlerReset(ctx context.Context, state *state.State, cmd Command, user database.User) error {
+	err := state.Db.ResetUsers(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to reset users: %v", err)
 	}
 
-	err = state.Db.ResetFeeds(context.Background())
+	err = state.Db.ResetFeeds(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to reset feeds: %v", err)
 	}
 
-	err = state.Db.ResetFeedFollows(context.Background())
+	err = state.Db.ResetFeedFollows(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to reset feed_follows: %v", err)
 	}
@@ -133,51 +303,43 @@ func handlerReset(state *state.State, cmd Command) error {
 	return nil
 }
 
-func handlerUsers(state *state.State, cmd Command) error {
-	users, err := state.Db.GetUsers(context.Background())
+func handlerUsers(ctx context.Context, state *state.State, cmd Command) error {
+	users, err := state.Db.ListUsers(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get users: %v", err)
 	}
 
-	loggedUserName := state.Config.CurrentUserName
+	loggedUserName := ""
+	if user, err := currentUser(ctx, state); err == nil {
+		loggedUserName = user.Name
+	}
 
 	fmt.Println("Registered users:")
 	for _, user := range users {
-		if user == loggedUserName {
-			fmt.Println("*", user, "(current)")
-		} else {
-			fmt.Println("*", user)
+		line := fmt.Sprintf("* %s", user.Name)
+		if user.Role == auth.RoleAdmin {
+			line += " (admin)"
 		}
+		if user.Name == loggedUserName {
+			line += " (current)"
+		}
+		fmt.Println(line)
 	}
 
 	return nil
 }
 
-func handlerAgg(state *state.State, cmd Command) error {
-	timeBetweenRequests := 1 * time.Minute
-	if len(cmd.Arguments) >= 1 {
-		timeArgument, err := time.ParseDuration(cmd.Arguments[0])
-		if err != nil {
-			return fmt.Errorf("error parsing first argument (time between requests): %v", err)
-		}
-		timeBetweenRequests = timeArgument
-	}
-
-	fmt.Printf("--- Collecting feeds avery %v ---\n", timeBetweenRequests)
-
-	ticker := time.NewTicker(timeBetweenRequests)
-	for ; ; <-ticker.C {
-		nextFeedName, err := feedscraper.ScrapeNextFeed(state.Db)
-		if err != nil {
-			fmt.Printf("error scraping feed: %v\n", err)
-		} else {
-			fmt.Printf("successfuly scraped posts from %s\n", nextFeedName)
-		}
-
+// describeURLChange explains why the URL of a feed changed.
+func describeURLChange(urlChange database.FeedUrlChange) string {
+	description := fmt.Sprintf("%s: moved from %s to %s (HTTP %d)",
+		urlChange.ChangedAt.Format(time.DateTime), urlChange.OldUrl, urlChange.NewUrl, urlChange.StatusCode)
+	if urlChange.Merged {
+		description += ", merged with the feed already at that URL"
 	}
+	return description
 }
 
-func handlerAddFeed(state *state.State, cmd Command, user database.User) error {
+func handlerAddFeed(ctx context.Context, state *state.State, cmd Command, user database.User) error {
 	if len(cmd.Arguments) < 2 {
 		return fmt.Errorf("not enough arguments for add feed command, expected 2, got %d", len(cmd.Arguments))
 	}
@@ -185,49 +347,81 @@ func handlerAddFeed(state *state.State, cmd Command, user database.User) error {
 	feedName := cmd.Arguments[0]
 	feedUrl := cmd.Arguments[1]
 
+	err := state.Fetcher.CheckURL(ctx, feedUrl)
+	if err != nil {
+		return err
+	}
+
 	feed, err := state.Db.CreateFeed(
-		context.Background(),
+		ctx,
 		database.CreateFeedParams{
 			ID:        uuid.New(),
 			Name:      feedName,
 			CreatedAt: time.Now(),
 			UpdatedAt: time.Now(),
 			Url:       feedUrl,
-			UserID:    user.ID,
+			UserID:    uuid.NullUUID{UUID: user.ID, Valid: true},
 		},
 	)
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("%+v", feed)
+	state.Logger.Debug("feed added", "feed_id", feed.ID, "feed_url", feed.Url)
 
-	followFeed(user, feed.Url, state.Db)
+	_, err = followFeed(ctx, user, feed.Url, state.Db)
+	if err != nil {
+		return fmt.Errorf("feed '%s' added, but following it failed: %v", feed.Name, err)
+	}
 
+	fmt.Printf("Feed '%s' added, %s is now following it.\n", feed.Name, user.Name)
 	return nil
 }
 
-func handlerFeeds(state *state.State, cmd Command) error {
-	feeds, err := state.Db.GetFeeds(context.Background())
+func handlerFeeds(ctx context.Context, state *state.State, cmd Command) error {
+	feeds, err := state.Db.GetFeeds(ctx)
+	if err != nil {
+		return err
+	}
+
+	urlChanges, err := state.Db.GetFeedURLChanges(ctx)
 	if err != nil {
 		return err
 	}
+	urlChangesByFeed := make(map[uuid.UUID][]database.FeedUrlChange)
+	for _, urlChange := range urlChanges {
+		urlChangesByFeed[urlChange.FeedID] = append(urlChangesByFeed[urlChange.FeedID], urlChange)
+	}
 
 	for _, feed := range feeds {
-		fmt.Printf("--- %s ---\nURL: %s\nUser: %s\n\n", feed.Name, feed.Url, feed.UserName)
+		owner := "(system)"
+		if feed.UserName.Valid {
+			owner = feed.UserName.String
+		}
+		fmt.Printf("--- %s ---\nURL: %s\nUser: %s\n", feed.Name, feed.Url, owner)
+		if feed.LastFetchError.Valid {
+			fmt.Printf("Last fetch failed: %s\n", feed.LastFetchError.String)
+		}
+		if feed.NextFetchAt.Valid && feed.NextFetchAt.Time.After(time.Now()) {
+			fmt.Printf("Next fetch: not before %s\n", feed.NextFetchAt.Time.Format(time.RFC1123))
+		}
+		for _, urlChange := range urlChangesByFeed[feed.ID] {
+			fmt.Println(describeURLChange(urlChange))
+		}
+		fmt.Println()
 	}
 
 	return nil
 }
 
-func handlerFollow(state *state.State, cmd Command, user database.User) error {
+func handlerFollow(ctx context.Context, state *state.State, cmd Command, user database.User) error {
 	if len(cmd.Arguments) < 1 {
 		return fmt.Errorf("expected 1 argument, got 0")
 	}
 
 	feedUrl := cmd.Arguments[0]
 
-	feedFollowResponse, err := followFeed(user, feedUrl, state.Db)
+	feedFollowResponse, err := followFeed(ctx, user, feedUrl, state.Db)
 	if err != nil {
 		return err
 	}
@@ -237,14 +431,14 @@ func handlerFollow(state *state.State, cmd Command, user database.User) error {
 	return nil
 }
 
-func followFeed(user database.User, feedUrl string, db *database.Queries) (database.CreateFeedFollowRow, error) {
-	feed, err := db.GetFeedByURL(context.Background(), feedUrl)
+func followFeed(ctx context.Context, user database.User, feedUrl string, db *database.Queries) (database.CreateFeedFollowRow, error) {
+	feed, err := db.GetFeedByURL(ctx, feedUrl)
 	if err != nil {
 		return database.CreateFeedFollowRow{}, err
 	}
 
 	feedFollowResponse, err := db.CreateFeedFollow(
-		context.Background(),
+		ctx,
 		database.CreateFeedFollowParams{
 			ID:        uuid.New(),
 			CreatedAt: time.Now(),
@@ -260,8 +454,8 @@ func followFeed(user database.User, feedUrl string, db *database.Queries) (datab
 	return feedFollowResponse, nil
 }
 
-func handlerFollowing(state *state.State, cmd Command, user database.User) error {
-	feedFollows, err := state.Db.GetFeedFollowsForUser(context.Background(), user.ID)
+func handlerFollowing(ctx context.Context, state *state.State, cmd Command, user database.User) error {
+	feedFollows, err := state.Db.GetFeedFollowsForUser(ctx, user.ID)
 	if err != nil {
 		return err
 	}
@@ -275,20 +469,20 @@ func handlerFollowing(state *state.State, cmd Command, user database.User) error
 	return nil
 }
 
-func handlerUnfollow(state *state.State, cmd Command, user database.User) error {
+func handlerUnfollow(ctx context.Context, state *state.State, cmd Command, user database.User) error {
 	if len(cmd.Arguments) < 1 {
 		return fmt.Errorf("expected 1 argument, got 0")
 	}
 
 	feedUrl := cmd.Arguments[0]
 
-	feed, err := state.Db.GetFeedByURL(context.Background(), feedUrl)
+	feed, err := state.Db.GetFeedByURL(ctx, feedUrl)
 	if err != nil {
 		return err
 	}
 
 	err = state.Db.DeleteFeedFollow(
-		context.Background(),
+		ctx,
 		database.DeleteFeedFollowParams{
 			UserID: user.ID,
 			FeedID: feed.ID,
@@ -303,7 +497,7 @@ func handlerUnfollow(state *state.State, cmd Command, user database.User) error
 	return nil
 }
 
-func handlerBrowse(state *state.State, cmd Command, user database.User) error {
+func handlerBrowse(ctx context.Context, state *state.State, cmd Command, user database.User) error {
 	limit := 2
 	if len(cmd.Arguments) >= 1 {
 		var err error
@@ -314,7 +508,7 @@ func handlerBrowse(state *state.State, cmd Command, user database.User) error {
 	}
 
 	posts, err := state.Db.GetPostsForUser(
-		context.Background(),
+		ctx,
 		database.GetPostsForUserParams{
 			UserID: user.ID,
 			Limit:  int32(limit),
@@ -324,13 +518,133 @@ func handlerBrowse(state *state.State, cmd Command, user database.User) error {
 		return fmt.Errorf("error getting posts for user %s: %v", user.Name, err)
 	}
 
+	width := outputWidth()
 	for _, post := range posts {
 		postDescription := ""
 		if post.Description.Valid {
-			postDescription = post.Description.String
+			postDescription = htmlrenderer.Render(post.Description.String, width)
+		}
+		fmt.Printf("%s\nPublish date: %v\n%s\nLink: %s\n\n", htmlrenderer.StripControl(post.Title), post.PublishedAt, postDescription, htmlrenderer.StripControl(post.Url))
+	}
+
+	return nil
+}
+
+func handlerTUI(ctx context.Context, state *state.State, cmd Command, user database.User) error {
+	return tui.Run(ctx, state.Db, user)
+}
+
+// outputWidth returns the width to wrap rendered post content to: the
+// terminal width when stdout is a terminal, or a fixed default otherwise.
+func outputWidth() int {
+	width, _, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 {
+		return defaultOutputWidth
+	}
+	return width
+}
+
+func handlerServe(ctx context.Context, state *state.State, cmd Command) error {
+	addr := defaultServeAddr
+	if len(cmd.Arguments) >= 1 {
+		addr = cmd.Arguments[0]
+	}
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           api.NewServer(state.Db, state.Fetcher),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	state.Logger.Info("serving the gator API", "addr", addr)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	state.Logger.Info("shutting down, waiting for requests in progress")
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
+}
+
+func handlerPromote(ctx context.Context, state *state.State, cmd Command, user database.User) error {
+	if len(cmd.Arguments) < 1 {
+		return fmt.Errorf("username argument is required for promote command")
+	}
+
+	target, err := state.Db.GetUser(ctx, cmd.Arguments[0])
+	if err != nil {
+		return fmt.Errorf("user '%s' does not exist", cmd.Arguments[0])
+	}
+	if target.Role == auth.RoleAdmin {
+		return fmt.Errorf("user '%s' is already an admin", target.Name)
+	}
+
+	err = state.Db.UpdateUserRole(
+		ctx,
+		database.UpdateUserRoleParams{
+			ID:   target.ID,
+			Role: auth.RoleAdmin,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to promote user '%s': %v", target.Name, err)
+	}
+
+	fmt.Printf("User '%s' is now an admin.\n", target.Name)
+	return nil
+}
+
+func handlerDemote(ctx context.Context, state *state.State, cmd Command, user database.User) error {
+	if len(cmd.Arguments) < 1 {
+		return fmt.Errorf("username argument is required for demote command")
+	}
+
+	target, err := state.Db.GetUser(ctx, cmd.Arguments[0])
+	if err != nil {
+		return fmt.Errorf("user '%s' does not exist", cmd.Arguments[0])
+	}
+	if target.Role != auth.RoleAdmin {
+		return fmt.Errorf("user '%s' is not an admin", target.Name)
+	}
+
+	// Counting the admins and demoting happen under a lock on the users, so
+	// two admins demoting each other at once cannot leave none.
+	err = state.Db.InTx(ctx, func(db *database.Queries) error {
+		if err := db.LockUsers(ctx); err != nil {
+			return fmt.Errorf("failed to lock users: %v", err)
+		}
+		adminCount, err := db.CountAdmins(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to count admins: %v", err)
 		}
-		fmt.Printf("%s\nPublish date: %v\n%s\nLink: %s\n\n", post.Title, post.PublishedAt, postDescription, post.Url)
+		if adminCount <= 1 {
+			return fmt.Errorf("cannot demote '%s', the last admin", target.Name)
+		}
+
+		err = db.UpdateUserRole(
+			ctx,
+			database.UpdateUserRoleParams{
+				ID:   target.ID,
+				Role: auth.RoleMember,
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to demote user '%s': %v", target.Name, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
+	fmt.Printf("User '%s' is now a member.\n", target.Name)
 	return nil
 }
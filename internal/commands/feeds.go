@@ -0,0 +1,160 @@
+package commands
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	auth "github.com/alancorleto/gator/internal/auth"
+	database "github.com/alancorleto/gator/internal/database"
+	state "github.com/alancorleto/gator/internal/state"
+	"github.com/google/uuid"
+)
+
+func handlerRemoveFeed(ctx context.Context, state *state.State, cmd Command, user database.User) error {
+	flags := flag.NewFlagSet("rmfeed", flag.ContinueOnError)
+	yes := flags.Bool("yes", false, "delete without asking for confirmation")
+	arguments, err := parseArguments(flags, cmd.Arguments)
+	if err != nil {
+		return err
+	}
+	if len(arguments) < 1 {
+		return fmt.Errorf("feed url argument is required for rmfeed command")
+	}
+
+	feed, err := managedFeed(ctx, state, arguments[0], user)
+	if err != nil {
+		return err
+	}
+
+	if !*yes {
+		followers, err := state.Db.CountFeedFollowers(ctx, feed.ID)
+		if err != nil {
+			return fmt.Errorf("failed to count followers of %s: %v", feed.Name, err)
+		}
+		posts, err := state.Db.CountPostsForFeed(ctx, feed.ID)
+		if err != nil {
+			return fmt.Errorf("failed to count posts of %s: %v", feed.Name, err)
+		}
+
+		fmt.Printf("Deleting '%s' also removes %d follow(s) and %d post(s).\n", feed.Name, followers, posts)
+		confirmed, err := confirm("Delete this feed?")
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			fmt.Println("Feed not deleted.")
+			return nil
+		}
+	}
+
+	err = state.Db.DeleteFeed(ctx, feed.ID)
+	if err != nil {
+		return fmt.Errorf("failed to delete feed %s: %v", feed.Name, err)
+	}
+
+	fmt.Printf("Feed '%s' deleted.\n", feed.Name)
+	return nil
+}
+
+func handlerRenameFeed(ctx context.Context, state *state.State, cmd Command, user database.User) error {
+	if len(cmd.Arguments) < 2 {
+		return fmt.Errorf("not enough arguments for renamefeed command, expected 2, got %d", len(cmd.Arguments))
+	}
+
+	feed, err := managedFeed(ctx, state, cmd.Arguments[0], user)
+	if err != nil {
+		return err
+	}
+	newName := cmd.Arguments[1]
+
+	_, err = state.Db.UpdateFeedName(
+		ctx,
+		database.UpdateFeedNameParams{
+			ID:   feed.ID,
+			Name: newName,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to rename feed %s: %v", feed.Name, err)
+	}
+
+	fmt.Printf("Feed '%s' renamed to '%s'.\n", feed.Name, newName)
+	return nil
+}
+
+func handlerSetFeedURL(ctx context.Context, state *state.State, cmd Command, user database.User) error {
+	if len(cmd.Arguments) < 2 {
+		return fmt.Errorf("not enough arguments for setfeedurl command, expected 2, got %d", len(cmd.Arguments))
+	}
+
+	feed, err := managedFeed(ctx, state, cmd.Arguments[0], user)
+	if err != nil {
+		return err
+	}
+
+	newUrl := cmd.Arguments[1]
+	err = state.Fetcher.CheckURL(ctx, newUrl)
+	if err != nil {
+		return err
+	}
+
+	_, err = state.Db.UpdateFeedURL(
+		ctx,
+		database.UpdateFeedURLParams{
+			ID:  feed.ID,
+			Url: newUrl,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to change the URL of feed %s: %v", feed.Name, err)
+	}
+
+	fmt.Printf("Feed '%s' now uses %s.\n", feed.Name, newUrl)
+	return nil
+}
+
+func handlerTransferFeed(ctx context.Context, state *state.State, cmd Command, user database.User) error {
+	if len(cmd.Arguments) < 2 {
+		return fmt.Errorf("not enough arguments for transferfeed command, expected 2, got %d", len(cmd.Arguments))
+	}
+
+	feed, err := managedFeed(ctx, state, cmd.Arguments[0], user)
+	if err != nil {
+		return err
+	}
+
+	newOwner, err := state.Db.GetUser(ctx, cmd.Arguments[1])
+	if err != nil {
+		return fmt.Errorf("user '%s' does not exist", cmd.Arguments[1])
+	}
+
+	_, err = state.Db.UpdateFeedOwner(
+		ctx,
+		database.UpdateFeedOwnerParams{
+			ID:     feed.ID,
+			UserID: uuid.NullUUID{UUID: newOwner.ID, Valid: true},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to transfer feed %s: %v", feed.Name, err)
+	}
+
+	fmt.Printf("Feed '%s' now belongs to '%s'.\n", feed.Name, newOwner.Name)
+	return nil
+}
+
+// managedFeed returns the feed with the given URL if user may modify it,
+// which owners and admins can. System-owned feeds are managed by admins only.
+func managedFeed(ctx context.Context, state *state.State, feedUrl string, user database.User) (database.Feed, error) {
+	feed, err := state.Db.GetFeedByURL(ctx, feedUrl)
+	if err != nil {
+		return database.Feed{}, fmt.Errorf("feed '%s' does not exist", feedUrl)
+	}
+
+	ownsFeed := feed.UserID.Valid && feed.UserID.UUID == user.ID
+	if !ownsFeed && user.Role != auth.RoleAdmin {
+		return database.Feed{}, fmt.Errorf("only the owner of '%s' or an admin can modify it", feed.Name)
+	}
+	return feed, nil
+}
@@ -0,0 +1,76 @@
+package commands
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	auth "github.com/alancorleto/gator/internal/auth"
+	"golang.org/x/term"
+)
+
+// stdin is shared by every prompt and by the non-interactive shell so that no
+// input is lost in a reader's buffer.
+var stdin = bufio.NewReader(os.Stdin)
+
+// readPassword prompts for a password without echoing it when stdin is a
+// terminal. Otherwise it reads the next line, so passwords can be piped in.
+func readPassword(prompt string) (string, error) {
+	fmt.Print(prompt)
+
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		password, err := term.ReadPassword(fd)
+		fmt.Println()
+		if err != nil {
+			return "", fmt.Errorf("error reading password: %v", err)
+		}
+		return string(password), nil
+	}
+
+	line, err := readLine()
+	if err != nil {
+		return "", fmt.Errorf("error reading password: %v", err)
+	}
+	return line, nil
+}
+
+// readNewPassword prompts for a new password twice and returns its hash.
+func readNewPassword() (string, error) {
+	password, err := readPassword("New password: ")
+	if err != nil {
+		return "", err
+	}
+	confirmation, err := readPassword("Confirm password: ")
+	if err != nil {
+		return "", err
+	}
+	if password != confirmation {
+		return "", fmt.Errorf("passwords do not match")
+	}
+
+	return auth.HashPassword(password)
+}
+
+func readLine() (string, error) {
+	line, err := stdin.ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// confirm asks a yes/no question and reports whether the answer was yes.
+func confirm(question string) (bool, error) {
+	fmt.Printf("%s [y/N] ", question)
+	answer, err := readLine()
+	if err != nil {
+		return false, fmt.Errorf("error reading answer: %v", err)
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
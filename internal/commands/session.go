@@ -0,0 +1,61 @@
+package commands
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	auth "github.com/alancorleto/gator/internal/auth"
+	database "github.com/alancorleto/gator/internal/database"
+	state "github.com/alancorleto/gator/internal/state"
+	"github.com/google/uuid"
+)
+
+const sessionDuration = 30 * 24 * time.Hour
+
+// currentUser returns the user of the session stored in the config file.
+func currentUser(ctx context.Context, state *state.State) (database.User, error) {
+	if state.Config.SessionToken == "" {
+		return database.User{}, fmt.Errorf("not logged in, use 'gator login <name>' first")
+	}
+
+	user, err := state.Db.GetUserBySessionToken(ctx, auth.HashToken(state.Config.SessionToken))
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, fmt.Errorf("session expired, use 'gator login <name>' to log in again")
+	}
+	if err != nil {
+		return database.User{}, err
+	}
+	return user, nil
+}
+
+// startSession creates a session for user and stores its token in the config
+// file, ending the session it replaces.
+func startSession(ctx context.Context, state *state.State, user database.User) error {
+	token, err := auth.MakeToken()
+	if err != nil {
+		return fmt.Errorf("failed to create session token: %v", err)
+	}
+
+	_, err = state.Db.CreateSession(
+		ctx,
+		database.CreateSessionParams{
+			ID:        uuid.New(),
+			TokenHash: auth.HashToken(token),
+			UserID:    user.ID,
+			CreatedAt: time.Now(),
+			ExpiresAt: time.Now().Add(sessionDuration),
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create session: %v", err)
+	}
+
+	if state.Config.SessionToken != "" {
+		state.Db.DeleteSession(ctx, auth.HashToken(state.Config.SessionToken))
+	}
+
+	return state.Config.SetSessionToken(token)
+}
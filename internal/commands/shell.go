@@ -0,0 +1,335 @@
+package commands
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	state "github.com/alancorleto/gator/internal/state"
+	"golang.org/x/term"
+)
+
+const (
+	historyFileName   = ".gator_history"
+	maxHistoryEntries = 500
+)
+
+func (c *Commands) handlerShell(ctx context.Context, state *state.State, cmd Command) error {
+	// The shell outlives interrupts of the commands it runs, which cancel the
+	// context they were given, see runShellLine.
+	ctx = context.WithoutCancel(ctx)
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return c.runScript(ctx, state, stdin)
+	}
+
+	history := loadHistory()
+	terminal := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, "")
+	terminal.History = history
+	terminal.AutoCompleteCallback = func(line string, pos int, key rune) (string, int, bool) {
+		if key != '\t' {
+			return "", 0, false
+		}
+		return c.complete(ctx, state, line, pos)
+	}
+
+	fmt.Println("gator shell. Type 'help' to list commands, 'exit' to quit.")
+
+	for {
+		terminal.SetPrompt(shellPrompt(ctx, state))
+
+		oldState, err := term.MakeRaw(fd)
+		if err != nil {
+			return fmt.Errorf("error setting terminal to raw mode: %v", err)
+		}
+		line, err := terminal.ReadLine()
+		term.Restore(fd, oldState)
+
+		if errors.Is(err, io.EOF) {
+			fmt.Println()
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error reading input: %v", err)
+		}
+
+		if done := c.runShellLine(ctx, state, line); done {
+			break
+		}
+	}
+
+	if err := history.save(); err != nil {
+		state.Logger.Warn("failed to save shell history", "error", err)
+	}
+
+	return nil
+}
+
+// runScript executes one command per line from a non-interactive input,
+// which allows piping commands into "gator shell". Commands that prompt for
+// input, such as passwords, read the following lines.
+func (c *Commands) runScript(ctx context.Context, state *state.State, input *bufio.Reader) error {
+	for {
+		line, err := input.ReadString('\n')
+		if line != "" {
+			if done := c.runShellLine(ctx, state, strings.TrimRight(line, "\r\n")); done {
+				return nil
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// runShellLine runs a single line of shell input and reports whether the
+// shell should exit.
+func (c *Commands) runShellLine(ctx context.Context, state *state.State, line string) bool {
+	args, err := splitArgs(line)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return false
+	}
+	if len(args) == 0 {
+		return false
+	}
+
+	switch args[0] {
+	case "exit", "quit":
+		return true
+	case "help":
+		fmt.Println("Available commands:")
+		for _, name := range c.names() {
+			fmt.Println("*", name)
+		}
+		fmt.Println("* help")
+		fmt.Println("* exit")
+		return false
+	case "shell":
+		fmt.Fprintln(os.Stderr, "Error: already inside the gator shell")
+		return false
+	}
+
+	// Each command stops on its own interrupt without ending the shell.
+	commandCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	err = c.Run(commandCtx, state, Command{Name: args[0], Arguments: args[1:]})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error executing command:", err)
+	}
+	return false
+}
+
+func shellPrompt(ctx context.Context, state *state.State) string {
+	user, err := currentUser(ctx, state)
+	if err != nil {
+		return "gator> "
+	}
+	return fmt.Sprintf("gator (%s)> ", user.Name)
+}
+
+func (c *Commands) names() []string {
+	names := make([]string, 0, len(c.CommandsMap))
+	for name := range c.CommandsMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// complete implements tab completion for the shell. The first word completes
+// to a command name; the first argument of commands that take a user name or
+// a feed URL completes against the database.
+func (c *Commands) complete(ctx context.Context, state *state.State, line string, pos int) (string, int, bool) {
+	prefix := line[:pos]
+	suffix := line[pos:]
+
+	wordStart := strings.LastIndex(prefix, " ") + 1
+	word := prefix[wordStart:]
+	previousWords := strings.Fields(prefix[:wordStart])
+
+	var candidates []string
+	switch {
+	case len(previousWords) == 0:
+		candidates = append(c.names(), "help", "exit")
+	case len(previousWords) == 1:
+		candidates = argumentCandidates(ctx, state, previousWords[0])
+	}
+
+	completion, ok := completeWord(word, candidates)
+	if !ok {
+		return "", 0, false
+	}
+
+	newPrefix := prefix[:wordStart] + completion
+	return newPrefix + suffix, len(newPrefix), true
+}
+
+func argumentCandidates(ctx context.Context, state *state.State, commandName string) []string {
+	switch commandName {
+	case "login", "renameuser", "deleteuser", "promote", "demote", "setpassword":
+		users, err := state.Db.GetUsers(ctx)
+		if err != nil {
+			return nil
+		}
+		return users
+	case "follow", "unfollow", "rmfeed", "renamefeed", "setfeedurl", "transferfeed", "refresh":
+		feeds, err := state.Db.GetFeeds(ctx)
+		if err != nil {
+			return nil
+		}
+		urls := make([]string, 0, len(feeds))
+		for _, feed := range feeds {
+			urls = append(urls, feed.Url)
+		}
+		return urls
+	}
+	return nil
+}
+
+// completeWord returns the completion of word among candidates. A single match
+// is completed in full followed by a space; several matches are completed up
+// to their longest common prefix.
+func completeWord(word string, candidates []string) (string, bool) {
+	var matches []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, word) {
+			matches = append(matches, candidate)
+		}
+	}
+
+	if len(matches) == 0 {
+		return "", false
+	}
+	if len(matches) == 1 {
+		return matches[0] + " ", true
+	}
+
+	common := matches[0]
+	for _, match := range matches[1:] {
+		for !strings.HasPrefix(match, common) {
+			common = common[:len(common)-1]
+		}
+	}
+	if len(common) <= len(word) {
+		return "", false
+	}
+	return common, true
+}
+
+// splitArgs splits a shell line into arguments, honoring single and double
+// quotes so that arguments such as feed names may contain spaces.
+func splitArgs(line string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg := false
+	var quote rune
+
+	for _, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in input")
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+
+	return args, nil
+}
+
+// fileHistory is a bounded shell history that is persisted to the user's home
+// directory between sessions.
+type fileHistory struct {
+	entries []string
+}
+
+func loadHistory() *fileHistory {
+	history := &fileHistory{}
+
+	path, err := historyFilePath()
+	if err != nil {
+		return history
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return history
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			history.Add(line)
+		}
+	}
+	return history
+}
+
+func (h *fileHistory) Add(entry string) {
+	if len(h.entries) > 0 && h.entries[len(h.entries)-1] == entry {
+		return
+	}
+	h.entries = append(h.entries, entry)
+	if len(h.entries) > maxHistoryEntries {
+		h.entries = h.entries[len(h.entries)-maxHistoryEntries:]
+	}
+}
+
+func (h *fileHistory) Len() int {
+	return len(h.entries)
+}
+
+func (h *fileHistory) At(idx int) string {
+	return h.entries[len(h.entries)-1-idx]
+}
+
+func (h *fileHistory) save() error {
+	path, err := historyFilePath()
+	if err != nil {
+		return err
+	}
+	data := strings.Join(h.entries, "\n") + "\n"
+	return os.WriteFile(path, []byte(data), 0600)
+}
+
+func historyFilePath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, historyFileName), nil
+}
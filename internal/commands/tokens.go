@@ -0,0 +1,184 @@
+package commands
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	auth "github.com/alancorleto/gator/internal/auth"
+	database "github.com/alancorleto/gator/internal/database"
+	state "github.com/alancorleto/gator/internal/state"
+	"github.com/google/uuid"
+)
+
+func handlerToken(ctx context.Context, state *state.State, cmd Command, user database.User) error {
+	if len(cmd.Arguments) < 1 {
+		return fmt.Errorf("expected a subcommand: create, list or revoke")
+	}
+
+	subcommand := cmd.Arguments[0]
+	arguments := cmd.Arguments[1:]
+
+	switch subcommand {
+	case "create":
+		return tokenCreate(ctx, state, arguments, user)
+	case "list":
+		return tokenList(ctx, state, user)
+	case "revoke":
+		return tokenRevoke(ctx, state, arguments, user)
+	}
+	return fmt.Errorf("unknown token subcommand: %s", subcommand)
+}
+
+func tokenCreate(ctx context.Context, state *state.State, arguments []string, user database.User) error {
+	flags := flag.NewFlagSet("token create", flag.ContinueOnError)
+	scopesFlag := flags.String("scopes", auth.ScopeRead, "comma-separated scopes granted to the token (read, write)")
+	expiresFlag := flags.String("expires", "", "lifetime of the token, such as 720h or 90d (default: never)")
+	arguments, err := parseArguments(flags, arguments)
+	if err != nil {
+		return err
+	}
+
+	if len(arguments) < 1 {
+		return fmt.Errorf("token name argument is required for token create command")
+	}
+	tokenName := arguments[0]
+
+	scopes, err := parseScopes(*scopesFlag)
+	if err != nil {
+		return err
+	}
+
+	expiresAt := sql.NullTime{}
+	if *expiresFlag != "" {
+		lifetime, err := parseLifetime(*expiresFlag)
+		if err != nil {
+			return err
+		}
+		expiresAt = sql.NullTime{Time: time.Now().Add(lifetime), Valid: true}
+	}
+
+	token, err := auth.MakeAPIToken()
+	if err != nil {
+		return fmt.Errorf("failed to create token: %v", err)
+	}
+
+	_, err = state.Db.CreateAPIToken(
+		ctx,
+		database.CreateAPITokenParams{
+			ID:        uuid.New(),
+			UserID:    user.ID,
+			Name:      tokenName,
+			TokenHash: auth.HashToken(token),
+			Scopes:    scopes,
+			CreatedAt: time.Now(),
+			ExpiresAt: expiresAt,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create token '%s': %v", tokenName, err)
+	}
+
+	fmt.Printf("Token '%s' created with scopes %s.\n", tokenName, strings.Join(scopes, ","))
+	fmt.Println("Copy it now, it will not be shown again:")
+	fmt.Println(token)
+	return nil
+}
+
+func tokenList(ctx context.Context, state *state.State, user database.User) error {
+	tokens, err := state.Db.GetAPITokensForUser(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get tokens for user %s: %v", user.Name, err)
+	}
+
+	fmt.Printf("API tokens of %s:\n", user.Name)
+	for _, token := range tokens {
+		expires := "never"
+		if token.ExpiresAt.Valid {
+			expires = token.ExpiresAt.Time.Format(time.DateTime)
+			if token.ExpiresAt.Time.Before(time.Now()) {
+				expires += " (expired)"
+			}
+		}
+		lastUsed := "never"
+		if token.LastUsedAt.Valid {
+			lastUsed = token.LastUsedAt.Time.Format(time.DateTime)
+		}
+
+		fmt.Printf("- %s\n  Scopes: %s\n  Created: %s\n  Expires: %s\n  Last used: %s\n",
+			token.Name,
+			strings.Join(token.Scopes, ","),
+			token.CreatedAt.Format(time.DateTime),
+			expires,
+			lastUsed,
+		)
+	}
+
+	return nil
+}
+
+func tokenRevoke(ctx context.Context, state *state.State, arguments []string, user database.User) error {
+	if len(arguments) < 1 {
+		return fmt.Errorf("token name argument is required for token revoke command")
+	}
+	tokenName := arguments[0]
+
+	deleted, err := state.Db.DeleteAPIToken(
+		ctx,
+		database.DeleteAPITokenParams{
+			UserID: user.ID,
+			Name:   tokenName,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to revoke token '%s': %v", tokenName, err)
+	}
+	if deleted == 0 {
+		return fmt.Errorf("token '%s' does not exist", tokenName)
+	}
+
+	fmt.Printf("Token '%s' revoked.\n", tokenName)
+	return nil
+}
+
+func parseScopes(value string) ([]string, error) {
+	var scopes []string
+	for _, scope := range strings.Split(value, ",") {
+		scope = strings.TrimSpace(scope)
+		if scope == "" || slices.Contains(scopes, scope) {
+			continue
+		}
+		if !slices.Contains(auth.Scopes, scope) {
+			return nil, fmt.Errorf("unknown scope '%s', expected one of: %s", scope, strings.Join(auth.Scopes, ", "))
+		}
+		scopes = append(scopes, scope)
+	}
+
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("at least one scope is required")
+	}
+	return scopes, nil
+}
+
+// parseLifetime parses a Go duration, also accepting a number of days such as
+// "90d".
+func parseLifetime(value string) (time.Duration, error) {
+	if days, found := strings.CutSuffix(value, "d"); found {
+		count, err := strconv.Atoi(days)
+		if err != nil || count <= 0 {
+			return 0, fmt.Errorf("invalid expiration '%s'", value)
+		}
+		return time.Duration(count) * 24 * time.Hour, nil
+	}
+
+	lifetime, err := time.ParseDuration(value)
+	if err != nil || lifetime <= 0 {
+		return 0, fmt.Errorf("invalid expiration '%s'", value)
+	}
+	return lifetime, nil
+}
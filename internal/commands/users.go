@@ -0,0 +1,217 @@
+package commands
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+
+	auth "github.com/alancorleto/gator/internal/auth"
+	database "github.com/alancorleto/gator/internal/database"
+	state "github.com/alancorleto/gator/internal/state"
+	"github.com/google/uuid"
+)
+
+// handlerDeleteUser deletes the logged in user, or any user when run by an
+// admin. Their feeds are either transferred to another user or, when other
+// users follow them, kept as system-owned feeds; feeds nobody else follows
+// are deleted.
+func handlerDeleteUser(ctx context.Context, state *state.State, cmd Command, user database.User) error {
+	flags := flag.NewFlagSet("deleteuser", flag.ContinueOnError)
+	transferTo := flags.String("transfer-to", "", "user who takes over the feeds of the deleted user")
+	yes := flags.Bool("yes", false, "delete without asking for confirmation")
+	arguments, err := parseArguments(flags, cmd.Arguments)
+	if err != nil {
+		return err
+	}
+
+	target := user
+	if len(arguments) >= 1 && arguments[0] != user.Name {
+		if user.Role != auth.RoleAdmin {
+			return fmt.Errorf("only admins can delete other users")
+		}
+		target, err = state.Db.GetUser(ctx, arguments[0])
+		if err != nil {
+			return fmt.Errorf("user '%s' does not exist", arguments[0])
+		}
+	}
+
+	ownerID := uuid.NullUUID{UUID: target.ID, Valid: true}
+	feeds, err := state.Db.GetFeedsOwnedByUser(ctx, ownerID)
+	if err != nil {
+		return fmt.Errorf("failed to get feeds of user %s: %v", target.Name, err)
+	}
+	sharedFeeds := 0
+	for _, feed := range feeds {
+		if feed.OtherFollowers > 0 {
+			sharedFeeds++
+		}
+	}
+
+	if len(feeds) > 0 && *transferTo == "" && !*yes {
+		fmt.Printf("'%s' owns %d feed(s):\n", target.Name, len(feeds))
+		for _, feed := range feeds {
+			fmt.Printf("* %s (%s), followed by %d other user(s)\n", feed.Name, feed.Url, feed.OtherFollowers)
+		}
+		fmt.Print("Transfer them to another user (leave empty to keep followed feeds as system-owned and delete the rest): ")
+		answer, err := readLine()
+		if err != nil {
+			return fmt.Errorf("error reading answer: %v", err)
+		}
+		*transferTo = strings.TrimSpace(answer)
+	}
+
+	var recipient *database.User
+	if len(feeds) > 0 && *transferTo != "" {
+		newOwner, err := state.Db.GetUser(ctx, *transferTo)
+		if err != nil {
+			return fmt.Errorf("user '%s' does not exist", *transferTo)
+		}
+		if newOwner.ID == target.ID {
+			return fmt.Errorf("cannot transfer feeds to the user being deleted")
+		}
+		recipient = &newOwner
+	}
+
+	if !*yes {
+		switch {
+		case len(feeds) == 0:
+			fmt.Printf("Deleting '%s' also removes their follows, sessions and API tokens.\n", target.Name)
+		case recipient != nil:
+			fmt.Printf("Deleting '%s' transfers %d feed(s) to '%s'.\n", target.Name, len(feeds), recipient.Name)
+		default:
+			fmt.Printf("Deleting '%s' makes %d feed(s) system-owned and deletes %d feed(s) nobody else follows.\n",
+				target.Name, sharedFeeds, len(feeds)-sharedFeeds)
+		}
+		confirmed, err := confirm(fmt.Sprintf("Delete user '%s'?", target.Name))
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			fmt.Println("User not deleted.")
+			return nil
+		}
+	}
+
+	// Moving the feeds and deleting the user happen together, so a failure
+	// halfway never leaves feeds transferred or deleted for a user that stays.
+	// The users are locked while the admins are counted, so two admins
+	// deleting each other at once cannot leave none.
+	var deleted int64
+	err = state.Db.InTx(ctx, func(db *database.Queries) error {
+		if err := db.LockUsers(ctx); err != nil {
+			return fmt.Errorf("failed to lock users: %v", err)
+		}
+		if target.Role == auth.RoleAdmin {
+			adminCount, err := db.CountAdmins(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to count admins: %v", err)
+			}
+			if adminCount <= 1 {
+				return fmt.Errorf("cannot delete '%s', the last admin; promote another user first", target.Name)
+			}
+		}
+
+		if recipient != nil {
+			err := db.TransferFeeds(
+				ctx,
+				database.TransferFeedsParams{
+					NewUserID: uuid.NullUUID{UUID: recipient.ID, Valid: true},
+					OldUserID: ownerID,
+				},
+			)
+			if err != nil {
+				return fmt.Errorf("failed to transfer feeds to %s: %v", recipient.Name, err)
+			}
+		} else if len(feeds) > 0 {
+			// Feeds that others still follow become system-owned when the
+			// user is deleted, the rest would have no readers left.
+			var err error
+			deleted, err = db.DeleteUnsharedFeedsForUser(ctx, ownerID)
+			if err != nil {
+				return fmt.Errorf("failed to delete feeds of %s: %v", target.Name, err)
+			}
+		}
+
+		err := db.DeleteUser(ctx, target.ID)
+		if err != nil {
+			return fmt.Errorf("failed to delete user %s: %v", target.Name, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if recipient != nil {
+		fmt.Printf("%d feed(s) transferred to '%s'.\n", len(feeds), recipient.Name)
+	} else if len(feeds) > 0 {
+		fmt.Printf("%d feed(s) deleted, %d feed(s) now system-owned.\n", deleted, int64(len(feeds))-deleted)
+	}
+
+	// The session went away with the user, forget its token too.
+	if target.ID == user.ID {
+		err = state.Config.SetSessionToken("")
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("User '%s' deleted.\n", target.Name)
+	return nil
+}
+
+// handlerRenameUser renames the logged in user, or any user when run by an
+// admin with both the old and the new name.
+func handlerRenameUser(ctx context.Context, state *state.State, cmd Command, user database.User) error {
+	if len(cmd.Arguments) < 1 {
+		return fmt.Errorf("new name argument is required for renameuser command")
+	}
+
+	target := user
+	newName := cmd.Arguments[0]
+	if len(cmd.Arguments) >= 2 {
+		if cmd.Arguments[0] != user.Name && user.Role != auth.RoleAdmin {
+			return fmt.Errorf("only admins can rename other users")
+		}
+		var err error
+		target, err = state.Db.GetUser(ctx, cmd.Arguments[0])
+		if err != nil {
+			return fmt.Errorf("user '%s' does not exist", cmd.Arguments[0])
+		}
+		newName = cmd.Arguments[1]
+	}
+
+	if _, err := state.Db.GetUser(ctx, newName); err == nil {
+		return fmt.Errorf("user '%s' already exists", newName)
+	}
+
+	_, err := state.Db.UpdateUserName(
+		ctx,
+		database.UpdateUserNameParams{
+			ID:   target.ID,
+			Name: newName,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to rename user %s: %v", target.Name, err)
+	}
+
+	fmt.Printf("User '%s' renamed to '%s'.\n", target.Name, newName)
+	return nil
+}
+
+func handlerLogout(ctx context.Context, state *state.State, cmd Command, user database.User) error {
+	err := state.Db.DeleteSession(ctx, auth.HashToken(state.Config.SessionToken))
+	if err != nil {
+		return fmt.Errorf("failed to end session: %v", err)
+	}
+
+	err = state.Config.SetSessionToken("")
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("User '%s' logged out.\n", user.Name)
+	return nil
+}
@@ -2,14 +2,80 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
+	"time"
 )
 
-const configFileName = ".gatorconfig.json"
+const (
+	configFileName = ".gatorconfig.json"
+	// configFileMode keeps the config file, which holds the session token,
+	// private to its owner.
+	configFileMode = 0600
+)
 
 type Config struct {
-	DbUrl           string `json:"db_url"`
-	CurrentUserName string `json:"current_user_name"`
+	DbUrl        string        `json:"db_url"`
+	SessionToken string        `json:"session_token,omitempty"`
+	Fetch        FetchConfig   `json:"fetch,omitzero"`
+	Log          LogConfig     `json:"log,omitzero"`
+	Tracing      TracingConfig `json:"tracing,omitzero"`
+}
+
+// FetchConfig tunes the HTTP client used to download feeds. Zero values
+// select the defaults of the feed fetcher.
+type FetchConfig struct {
+	ConnectTimeout  Duration `json:"connect_timeout,omitzero"`
+	Timeout         Duration `json:"timeout,omitzero"`
+	MaxBodyBytes    int64    `json:"max_body_bytes,omitzero"`
+	UserAgent       string   `json:"user_agent,omitempty"`
+	ContactURL      string   `json:"contact_url,omitempty"`
+	StrictXML       bool     `json:"strict_xml,omitempty"`
+	AllowedHosts    []string `json:"allowed_hosts,omitempty"`
+	MaxDepth        int      `json:"max_depth,omitzero"`
+	MaxItems        int      `json:"max_items,omitzero"`
+	MaxFieldBytes   int      `json:"max_field_bytes,omitzero"`
+	HostInterval    Duration `json:"host_interval,omitzero"`
+	HostBurst       int      `json:"host_burst,omitzero"`
+	HostConcurrency int      `json:"host_concurrency,omitzero"`
+	MaxRetryAfter   Duration `json:"max_retry_after,omitzero"`
+	MaxRetries      int      `json:"max_retries,omitzero"`
+	RetryBudget     Duration `json:"retry_budget,omitzero"`
+}
+
+// LogConfig selects which diagnostics are logged and how. Zero values select
+// the defaults of the logging package.
+type LogConfig struct {
+	Level  string `json:"level,omitempty"`
+	Format string `json:"format,omitempty"`
+}
+
+// TracingConfig selects where the spans of fetches and scrapes are
+// exported. Zero values select the defaults of the tracing package.
+type TracingConfig struct {
+	Exporter string `json:"exporter,omitempty"`
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+// Duration is a time.Duration written as a string such as "30s" in the
+// config file.
+type Duration time.Duration
+
+func (duration Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(duration).String())
+}
+
+func (duration *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("duration must be a string such as \"30s\": %v", err)
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*duration = Duration(parsed)
+	return nil
 }
 
 func Read() (*Config, error) {
@@ -29,8 +95,8 @@ func Read() (*Config, error) {
 	return config, nil
 }
 
-func (config *Config) SetUser(userName string) error {
-	config.CurrentUserName = userName
+func (config *Config) SetSessionToken(token string) error {
+	config.SessionToken = token
 
 	file, err := openConfigFile(os.O_RDWR | os.O_CREATE | os.O_TRUNC)
 	if err != nil {
@@ -38,6 +104,13 @@ func (config *Config) SetUser(userName string) error {
 	}
 	defer file.Close()
 
+	// The session token lets anyone who reads it act as the user, so files
+	// created readable by others before it was stored there are tightened.
+	err = file.Chmod(configFileMode)
+	if err != nil {
+		return err
+	}
+
 	encoder := json.NewEncoder(file)
 	err = encoder.Encode(config)
 	if err != nil {
@@ -54,7 +127,7 @@ func openConfigFile(flags int) (*os.File, error) {
 	}
 
 	filePath := homeDir + "/" + configFileName
-	file, err := os.OpenFile(filePath, flags, 0644)
+	file, err := os.OpenFile(filePath, flags, configFileMode)
 	if err != nil {
 		return nil, err
 	}
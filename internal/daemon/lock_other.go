@@ -0,0 +1,11 @@
+//go:build !unix
+
+package daemon
+
+import "os"
+
+// lockFile does nothing where flock is not available: the PID file is still
+// written, but does not keep a second daemon from starting.
+func lockFile(file *os.File) error {
+	return nil
+}
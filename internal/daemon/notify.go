@@ -0,0 +1,30 @@
+package daemon
+
+import (
+	"net"
+	"os"
+	"strings"
+)
+
+// Notify sends a state such as "READY=1" or "STATUS=..." to the service
+// manager, following the sd_notify protocol of systemd. It does nothing when
+// the process was not started with a notification socket.
+func Notify(state string) error {
+	socketPath := os.Getenv("NOTIFY_SOCKET")
+	if socketPath == "" {
+		return nil
+	}
+	// A leading @ stands for Linux's abstract socket namespace.
+	if strings.HasPrefix(socketPath, "@") {
+		socketPath = "\x00" + socketPath[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socketPath, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	return err
+}
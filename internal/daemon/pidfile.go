@@ -0,0 +1,96 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// PIDFile is a file holding the process ID of a running daemon, locked for as
+// long as the daemon runs so that a second one refuses to start.
+type PIDFile struct {
+	path string
+	file *os.File
+}
+
+// AcquirePIDFile locks the file at path, creating it if needed, and writes
+// the ID of the current process into it. It fails if another process holds
+// the lock. A file left behind by a daemon that crashed is not locked, so it
+// does not get in the way.
+func AcquirePIDFile(path string) (*PIDFile, error) {
+	for {
+		file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open PID file: %v", err)
+		}
+
+		if err := lockFile(file); err != nil {
+			content, _ := os.ReadFile(path)
+			file.Close()
+			if pid := strings.TrimSpace(string(content)); pid != "" {
+				return nil, fmt.Errorf("another gator daemon is running with PID %s (%s)", pid, path)
+			}
+			return nil, fmt.Errorf("failed to lock PID file %s: %v", path, err)
+		}
+
+		// The daemon that held the lock may have removed the file between
+		// our open and lock, leaving us a lock on a file nobody else can
+		// see. Start over until the locked file is the one at path.
+		current, err := isCurrentFile(file, path)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to check PID file: %v", err)
+		}
+		if !current {
+			file.Close()
+			continue
+		}
+
+		return writePID(file, path)
+	}
+}
+
+// isCurrentFile reports whether path still names the open file.
+func isCurrentFile(file *os.File, path string) (bool, error) {
+	openInfo, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+	pathInfo, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return os.SameFile(openInfo, pathInfo), nil
+}
+
+// writePID replaces the content of the locked file with our process ID.
+func writePID(file *os.File, path string) (*PIDFile, error) {
+	if err := file.Truncate(0); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to write PID file: %v", err)
+	}
+	if _, err := file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to write PID file: %v", err)
+	}
+
+	return &PIDFile{path: path, file: file}, nil
+}
+
+// Release removes the PID file and gives up the lock.
+func (pidFile *PIDFile) Release() error {
+	// The file is removed before it is unlocked: a daemon that opened it
+	// earlier and locks it once we are done then finds no file, or another
+	// one, at path and starts over, see AcquirePIDFile.
+	removeErr := os.Remove(pidFile.path)
+	closeErr := pidFile.file.Close()
+	if removeErr != nil {
+		return removeErr
+	}
+	return closeErr
+}
@@ -0,0 +1,72 @@
+//go:build unix
+
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestAcquirePIDFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gator.pid")
+
+	pidFile, err := AcquirePIDFile(path)
+	if err != nil {
+		t.Fatalf("AcquirePIDFile() failed: %v", err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.TrimSpace(string(content)), strconv.Itoa(os.Getpid()); got != want {
+		t.Errorf("PID file holds %q, want %q", got, want)
+	}
+
+	_, err = AcquirePIDFile(path)
+	if err == nil {
+		t.Fatal("second AcquirePIDFile() succeeded while the first holds the lock")
+	}
+	if !strings.Contains(err.Error(), "another gator daemon is running with PID "+strconv.Itoa(os.Getpid())) {
+		t.Errorf("second AcquirePIDFile() = %v, want it to name the running daemon", err)
+	}
+
+	if err := pidFile.Release(); err != nil {
+		t.Fatalf("Release() failed: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("PID file still exists after Release(): %v", err)
+	}
+
+	pidFile, err = AcquirePIDFile(path)
+	if err != nil {
+		t.Fatalf("AcquirePIDFile() after Release() failed: %v", err)
+	}
+	if err := pidFile.Release(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAcquirePIDFileLeftBehind(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gator.pid")
+	// A daemon that crashed leaves its PID file, but not its lock.
+	if err := os.WriteFile(path, []byte("99999\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	pidFile, err := AcquirePIDFile(path)
+	if err != nil {
+		t.Fatalf("AcquirePIDFile() over a stale file failed: %v", err)
+	}
+	defer pidFile.Release()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(content), strconv.Itoa(os.Getpid())+"\n"; got != want {
+		t.Errorf("PID file holds %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,277 @@
+package daemon
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"slices"
+	"time"
+
+	auth "github.com/alancorleto/gator/internal/auth"
+	database "github.com/alancorleto/gator/internal/database"
+	metrics "github.com/alancorleto/gator/internal/metrics"
+)
+
+// readyTimeout bounds the database check of /readyz.
+const readyTimeout = 2 * time.Second
+
+// Progress is what the aggregation loop reports about its work.
+type Progress struct {
+	StartedAt time.Time
+	// LastRoundAt is when the last round of scrapes ended, or zero before
+	// the first one does.
+	LastRoundAt     time.Time
+	LastSuccessAt   time.Time
+	LastSuccessFeed string
+	Scraped         int
+	Failed          int
+	NewPosts        int
+	Retries         int
+}
+
+// StatusServer serves the health of a running aggregator: /healthz reports
+// whether rounds of scrapes keep ending, /readyz whether the database can be
+// reached, and /status, as JSON, and / show what was done so far and which
+// feeds fail. /metrics serves the Prometheus metrics. As the status pages
+// reveal feed URLs and errors, they require the API token of an admin with
+// the read scope; the health checks and metrics are left open for probes and
+// scrapers. It implements http.Handler.
+type StatusServer struct {
+	db         *database.Queries
+	progress   func() Progress
+	staleAfter time.Duration
+	mux        *http.ServeMux
+}
+
+// NewStatusServer reports the progress returned by the given function. The
+// aggregator is considered stuck when no round ended within staleAfter.
+func NewStatusServer(db *database.Queries, progress func() Progress, staleAfter time.Duration) *StatusServer {
+	s := &StatusServer{
+		db:         db,
+		progress:   progress,
+		staleAfter: staleAfter,
+		mux:        http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("GET /healthz", s.handlerHealthz)
+	s.mux.HandleFunc("GET /readyz", s.handlerReadyz)
+	s.mux.HandleFunc("GET /status", s.middlewareAdminToken(s.handlerStatusJSON))
+	s.mux.Handle("GET /metrics", metrics.Handler())
+	s.mux.HandleFunc("GET /{$}", s.middlewareAdminToken(s.handlerStatusPage))
+
+	return s
+}
+
+func (s *StatusServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// middlewareAdminToken requires an API token of an admin that carries the
+// read scope, sent as a bearer token like for the API.
+func (s *StatusServer) middlewareAdminToken(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="gator"`)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		tokenUser, err := s.db.GetUserByAPIToken(r.Context(), auth.HashToken(token))
+		if errors.Is(err, sql.ErrNoRows) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="gator", error="invalid_token"`)
+			http.Error(w, "invalid or expired token", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			http.Error(w, "database unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		if tokenUser.Role != auth.RoleAdmin || !slices.Contains(tokenUser.Scopes, auth.ScopeRead) {
+			http.Error(w, "the status pages require the token of an admin with the 'read' scope", http.StatusForbidden)
+			return
+		}
+		s.db.MarkAPITokenUsed(r.Context(), tokenUser.TokenID)
+
+		handler(w, r)
+	}
+}
+
+func (s *StatusServer) handlerHealthz(w http.ResponseWriter, r *http.Request) {
+	progress := s.progress()
+	lastActivity := progress.LastRoundAt
+	if lastActivity.IsZero() {
+		lastActivity = progress.StartedAt
+	}
+
+	if since := time.Since(lastActivity); since > s.staleAfter {
+		http.Error(w, fmt.Sprintf("no round of scrapes ended in %v", since.Round(time.Second)), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+func (s *StatusServer) handlerReadyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+
+	if _, err := s.db.GetFeedQueueStats(ctx); err != nil {
+		http.Error(w, fmt.Sprintf("database unavailable: %v", err), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ready")
+}
+
+type statusResponse struct {
+	StartedAt       time.Time           `json:"started_at"`
+	LastRoundAt     *time.Time          `json:"last_round_at"`
+	LastSuccessAt   *time.Time          `json:"last_success_at"`
+	LastSuccessFeed string              `json:"last_success_feed,omitempty"`
+	Scraped         int                 `json:"scraped"`
+	Failed          int                 `json:"failed"`
+	NewPosts        int                 `json:"new_posts"`
+	Retries         int                 `json:"retries"`
+	Queue           queueResponse       `json:"queue"`
+	FeedErrors      []feedErrorResponse `json:"feed_errors"`
+}
+
+type queueResponse struct {
+	Due          int64 `json:"due"`
+	Deferred     int64 `json:"deferred"`
+	NeverFetched int64 `json:"never_fetched"`
+}
+
+type feedErrorResponse struct {
+	Name          string     `json:"name"`
+	Url           string     `json:"url"`
+	Error         string     `json:"error"`
+	LastFetchedAt *time.Time `json:"last_fetched_at"`
+	NextFetchAt   *time.Time `json:"next_fetch_at"`
+}
+
+func (s *StatusServer) status(ctx context.Context) (statusResponse, error) {
+	progress := s.progress()
+	response := statusResponse{
+		StartedAt:       progress.StartedAt,
+		LastRoundAt:     optionalTime(progress.LastRoundAt),
+		LastSuccessAt:   optionalTime(progress.LastSuccessAt),
+		LastSuccessFeed: progress.LastSuccessFeed,
+		Scraped:         progress.Scraped,
+		Failed:          progress.Failed,
+		NewPosts:        progress.NewPosts,
+		Retries:         progress.Retries,
+		FeedErrors:      []feedErrorResponse{},
+	}
+
+	stats, err := s.db.GetFeedQueueStats(ctx)
+	if err != nil {
+		return statusResponse{}, fmt.Errorf("failed to get feed queue: %v", err)
+	}
+	response.Queue = queueResponse{
+		Due:          stats.Due,
+		Deferred:     stats.Deferred,
+		NeverFetched: stats.NeverFetched,
+	}
+
+	feeds, err := s.db.GetFeedsWithFetchErrors(ctx)
+	if err != nil {
+		return statusResponse{}, fmt.Errorf("failed to get feed errors: %v", err)
+	}
+	for _, feed := range feeds {
+		feedError := feedErrorResponse{
+			Name:  feed.Name,
+			Url:   feed.Url,
+			Error: feed.LastFetchError.String,
+		}
+		if feed.LastFetchedAt.Valid {
+			feedError.LastFetchedAt = &feed.LastFetchedAt.Time
+		}
+		if feed.NextFetchAt.Valid && feed.NextFetchAt.Time.After(time.Now()) {
+			feedError.NextFetchAt = &feed.NextFetchAt.Time
+		}
+		response.FeedErrors = append(response.FeedErrors, feedError)
+	}
+
+	return response, nil
+}
+
+func (s *StatusServer) handlerStatusJSON(w http.ResponseWriter, r *http.Request) {
+	response, err := s.status(r.Context())
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (s *StatusServer) handlerStatusPage(w http.ResponseWriter, r *http.Request) {
+	response, err := s.status(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	statusPage.Execute(w, response)
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+var statusPage = template.Must(template.New("status").Funcs(template.FuncMap{
+	"since": func(t time.Time) string {
+		return time.Since(t).Round(time.Second).String() + " ago"
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>gator aggregator status</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { text-align: left; padding: 0.25em 1em 0.25em 0; vertical-align: top; }
+</style>
+</head>
+<body>
+<h1>gator aggregator</h1>
+<table>
+<tr><th>Running since</th><td>{{.StartedAt.Format "2006-01-02 15:04:05"}} ({{since .StartedAt}})</td></tr>
+<tr><th>Last round</th><td>{{with .LastRoundAt}}{{since .}}{{else}}none yet{{end}}</td></tr>
+<tr><th>Last successful fetch</th><td>{{with .LastSuccessAt}}{{$.LastSuccessFeed}}, {{since .}}{{else}}none yet{{end}}</td></tr>
+<tr><th>Feeds scraped</th><td>{{.Scraped}} ({{.Failed}} failed, {{.Retries}} retries)</td></tr>
+<tr><th>New posts</th><td>{{.NewPosts}}</td></tr>
+<tr><th>Queue</th><td>{{.Queue.Due}} due, {{.Queue.Deferred}} deferred, {{.Queue.NeverFetched}} never fetched</td></tr>
+</table>
+<h2>Failing feeds</h2>
+{{if .FeedErrors}}
+<table>
+<tr><th>Feed</th><th>Error</th><th>Last fetch</th><th>Next fetch</th></tr>
+{{range .FeedErrors}}
+<tr>
+<td>{{.Name}}<br><small>{{.Url}}</small></td>
+<td>{{.Error}}</td>
+<td>{{with .LastFetchedAt}}{{since .}}{{end}}</td>
+<td>{{with .NextFetchAt}}not before {{.Format "2006-01-02 15:04:05"}}{{end}}</td>
+</tr>
+{{end}}
+</table>
+{{else}}
+<p>None.</p>
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,175 @@
+package daemon
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	auth "github.com/alancorleto/gator/internal/auth"
+	database "github.com/alancorleto/gator/internal/database"
+	"github.com/google/uuid"
+)
+
+const testToken = "gator_test_token"
+
+var (
+	testUserID  = uuid.MustParse("11111111-1111-1111-1111-111111111111")
+	testTokenID = uuid.MustParse("22222222-2222-2222-2222-222222222222")
+	testTime    = time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+)
+
+// newTestStatusServer serves the status pages on a mocked database. Expected
+// queries are matched by their sqlc name, such as "GetUserByAPIToken".
+func newTestStatusServer(t *testing.T) (*httptest.Server, sqlmock.Sqlmock) {
+	t.Helper()
+
+	matchName := sqlmock.QueryMatcherFunc(func(expectedName, actualSQL string) error {
+		if !strings.HasPrefix(actualSQL, "-- name: "+expectedName+" ") {
+			return fmt.Errorf("query %q is not %s", strings.SplitN(actualSQL, "\n", 2)[0], expectedName)
+		}
+		return nil
+	})
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(matchName))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	progress := func() Progress {
+		return Progress{StartedAt: time.Now(), Scraped: 3, Failed: 1}
+	}
+	server := httptest.NewServer(NewStatusServer(database.New(db), progress, time.Hour))
+	t.Cleanup(func() {
+		server.Close()
+		db.Close()
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+	return server, mock
+}
+
+// expectToken makes testToken resolve to a user with role and scopes.
+func expectToken(mock sqlmock.Sqlmock, role string, scopes ...string) {
+	mock.ExpectQuery("GetUserByAPIToken").
+		WithArgs(auth.HashToken(testToken)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "name", "password_hash", "role", "token_id", "scopes"}).
+			AddRow(testUserID, testTime, testTime, "alice", "hash", role, testTokenID, "{"+strings.Join(scopes, ",")+"}"))
+}
+
+func get(t *testing.T, server *httptest.Server, path string, token string) (*http.Response, string) {
+	t.Helper()
+
+	req, err := http.NewRequest("GET", server.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(data)
+}
+
+func TestStatusRequiresAdminToken(t *testing.T) {
+	for _, path := range []string{"/status", "/"} {
+		t.Run(path+" without token", func(t *testing.T) {
+			server, _ := newTestStatusServer(t)
+			resp, body := get(t, server, path, "")
+			if resp.StatusCode != http.StatusUnauthorized {
+				t.Fatalf("status = %d, want %d: %s", resp.StatusCode, http.StatusUnauthorized, body)
+			}
+			if resp.Header.Get("WWW-Authenticate") == "" {
+				t.Error("missing WWW-Authenticate header")
+			}
+		})
+
+		t.Run(path+" with an unknown token", func(t *testing.T) {
+			server, mock := newTestStatusServer(t)
+			mock.ExpectQuery("GetUserByAPIToken").
+				WithArgs(auth.HashToken(testToken)).
+				WillReturnError(sql.ErrNoRows)
+			resp, body := get(t, server, path, testToken)
+			if resp.StatusCode != http.StatusUnauthorized {
+				t.Fatalf("status = %d, want %d: %s", resp.StatusCode, http.StatusUnauthorized, body)
+			}
+		})
+
+		t.Run(path+" with a member token", func(t *testing.T) {
+			server, mock := newTestStatusServer(t)
+			expectToken(mock, auth.RoleMember, auth.ScopeRead, auth.ScopeWrite)
+			resp, body := get(t, server, path, testToken)
+			if resp.StatusCode != http.StatusForbidden {
+				t.Fatalf("status = %d, want %d: %s", resp.StatusCode, http.StatusForbidden, body)
+			}
+		})
+
+		t.Run(path+" with an admin token without the read scope", func(t *testing.T) {
+			server, mock := newTestStatusServer(t)
+			expectToken(mock, auth.RoleAdmin, auth.ScopeWrite)
+			resp, body := get(t, server, path, testToken)
+			if resp.StatusCode != http.StatusForbidden {
+				t.Fatalf("status = %d, want %d: %s", resp.StatusCode, http.StatusForbidden, body)
+			}
+		})
+	}
+}
+
+func TestStatusJSON(t *testing.T) {
+	server, mock := newTestStatusServer(t)
+	expectToken(mock, auth.RoleAdmin, auth.ScopeRead)
+	mock.ExpectExec("MarkAPITokenUsed").
+		WithArgs(testTokenID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("GetFeedQueueStats").
+		WillReturnRows(sqlmock.NewRows([]string{"due", "deferred", "never_fetched"}).AddRow(2, 5, 1))
+	mock.ExpectQuery("GetFeedsWithFetchErrors").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "url", "last_fetched_at", "last_fetch_error", "next_fetch_at"}).
+			AddRow(uuid.New(), "Broken", "http://example.com/feed", testTime, "HTTP 500", nil))
+
+	resp, body := get(t, server, "/status", testToken)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", resp.StatusCode, http.StatusOK, body)
+	}
+
+	var status statusResponse
+	if err := json.Unmarshal([]byte(body), &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.Scraped != 3 || status.Failed != 1 {
+		t.Errorf("scraped %d and failed %d, want 3 and 1", status.Scraped, status.Failed)
+	}
+	if status.Queue != (queueResponse{Due: 2, Deferred: 5, NeverFetched: 1}) {
+		t.Errorf("queue = %+v", status.Queue)
+	}
+	if len(status.FeedErrors) != 1 || status.FeedErrors[0].Error != "HTTP 500" {
+		t.Errorf("feed errors = %+v", status.FeedErrors)
+	}
+}
+
+func TestHealthEndpointsAreOpen(t *testing.T) {
+	server, mock := newTestStatusServer(t)
+	mock.ExpectQuery("GetFeedQueueStats").
+		WillReturnRows(sqlmock.NewRows([]string{"due", "deferred", "never_fetched"}).AddRow(0, 0, 0))
+
+	for _, path := range []string{"/healthz", "/readyz"} {
+		resp, body := get(t, server, path, "")
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("%s status = %d, want %d: %s", path, resp.StatusCode, http.StatusOK, body)
+		}
+	}
+}
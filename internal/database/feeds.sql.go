@@ -7,11 +7,45 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+const claimNextFeedToFetch = `-- name: ClaimNextFeedToFetch :one
+UPDATE feeds
+SET last_fetched_at = NOW(),
+    updated_at = NOW()
+WHERE id = (
+    SELECT id
+    FROM feeds
+    WHERE (next_fetch_at IS NULL OR next_fetch_at <= NOW())
+    AND ($1::timestamp IS NULL OR last_fetched_at IS NULL OR last_fetched_at < $1)
+    ORDER BY last_fetched_at ASC NULLS FIRST
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, last_fetch_error, next_fetch_at
+`
+
+func (q *Queries) ClaimNextFeedToFetch(ctx context.Context, fetchedBefore sql.NullTime) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, claimNextFeedToFetch, fetchedBefore)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.LastFetchError,
+		&i.NextFetchAt,
+	)
+	return i, err
+}
+
 const createFeed = `-- name: CreateFeed :one
 INSERT INTO feeds (id, created_at, updated_at, name, url, user_id)
 VALUES (
@@ -22,7 +56,7 @@ VALUES (
     $5,
     $6
 )
-RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, last_fetch_error, next_fetch_at
 `
 
 type CreateFeedParams struct {
@@ -31,7 +65,7 @@ type CreateFeedParams struct {
 	UpdatedAt time.Time
 	Name      string
 	Url       string
-	UserID    uuid.UUID
+	UserID    uuid.NullUUID
 }
 
 func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, error) {
@@ -52,12 +86,52 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.LastFetchError,
+		&i.NextFetchAt,
 	)
 	return i, err
 }
 
+const deleteFeed = `-- name: DeleteFeed :exec
+DELETE FROM feeds
+WHERE id = $1
+`
+
+func (q *Queries) DeleteFeed(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteFeed, id)
+	return err
+}
+
+const deleteUnsharedFeedsForUser = `-- name: DeleteUnsharedFeedsForUser :execrows
+DELETE FROM feeds
+WHERE user_id = $1
+AND NOT EXISTS (
+    SELECT 1 FROM feed_follows
+    WHERE feed_follows.feed_id = feeds.id AND feed_follows.user_id <> feeds.user_id
+)
+`
+
+func (q *Queries) DeleteUnsharedFeedsForUser(ctx context.Context, userID uuid.NullUUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteUnsharedFeedsForUser, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getDatabaseTime = `-- name: GetDatabaseTime :one
+SELECT NOW()::timestamp AS now
+`
+
+func (q *Queries) GetDatabaseTime(ctx context.Context) (time.Time, error) {
+	row := q.db.QueryRowContext(ctx, getDatabaseTime)
+	var now time.Time
+	err := row.Scan(&now)
+	return now, err
+}
+
 const getFeedByURL = `-- name: GetFeedByURL :one
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, last_fetch_error, next_fetch_at
 FROM feeds
 WHERE url = $1
 `
@@ -73,21 +147,47 @@ func (q *Queries) GetFeedByURL(ctx context.Context, url string) (Feed, error) {
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.LastFetchError,
+		&i.NextFetchAt,
 	)
 	return i, err
 }
 
+const getFeedQueueStats = `-- name: GetFeedQueueStats :one
+SELECT
+    COUNT(*) FILTER (WHERE next_fetch_at IS NULL OR next_fetch_at <= NOW()) AS due,
+    COUNT(*) FILTER (WHERE next_fetch_at > NOW()) AS deferred,
+    COUNT(*) FILTER (WHERE last_fetched_at IS NULL) AS never_fetched
+FROM feeds
+`
+
+type GetFeedQueueStatsRow struct {
+	Due          int64
+	Deferred     int64
+	NeverFetched int64
+}
+
+func (q *Queries) GetFeedQueueStats(ctx context.Context) (GetFeedQueueStatsRow, error) {
+	row := q.db.QueryRowContext(ctx, getFeedQueueStats)
+	var i GetFeedQueueStatsRow
+	err := row.Scan(&i.Due, &i.Deferred, &i.NeverFetched)
+	return i, err
+}
+
 const getFeeds = `-- name: GetFeeds :many
-SELECT feeds.name, feeds.url, users.name AS user_name
+SELECT feeds.id, feeds.name, feeds.url, users.name AS user_name, feeds.last_fetch_error, feeds.next_fetch_at
 FROM feeds
-INNER JOIN users
+LEFT JOIN users
 ON feeds.user_id = users.id
 `
 
 type GetFeedsRow struct {
-	Name     string
-	Url      string
-	UserName string
+	ID             uuid.UUID
+	Name           string
+	Url            string
+	UserName       sql.NullString
+	LastFetchError sql.NullString
+	NextFetchAt    sql.NullTime
 }
 
 func (q *Queries) GetFeeds(ctx context.Context) ([]GetFeedsRow, error) {
@@ -99,7 +199,14 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]GetFeedsRow, error) {
 	var items []GetFeedsRow
 	for rows.Next() {
 		var i GetFeedsRow
-		if err := rows.Scan(&i.Name, &i.Url, &i.UserName); err != nil {
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Url,
+			&i.UserName,
+			&i.LastFetchError,
+			&i.NextFetchAt,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -113,15 +220,180 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]GetFeedsRow, error) {
 	return items, nil
 }
 
-const getNextFeedToFetch = `-- name: GetNextFeedToFetch :one
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at
+const getFeedsOwnedByUser = `-- name: GetFeedsOwnedByUser :many
+SELECT feeds.id, feeds.created_at, feeds.updated_at, feeds.name, feeds.url, feeds.user_id, feeds.last_fetched_at, feeds.last_fetch_error, feeds.next_fetch_at, (
+    SELECT COUNT(*) FROM feed_follows
+    WHERE feed_follows.feed_id = feeds.id AND feed_follows.user_id <> feeds.user_id
+) AS other_followers
 FROM feeds
-ORDER BY last_fetched_at ASC NULLS FIRST
-LIMIT 1
+WHERE feeds.user_id = $1
+ORDER BY feeds.name
+`
+
+type GetFeedsOwnedByUserRow struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Name           string
+	Url            string
+	UserID         uuid.NullUUID
+	LastFetchedAt  sql.NullTime
+	LastFetchError sql.NullString
+	NextFetchAt    sql.NullTime
+	OtherFollowers int64
+}
+
+func (q *Queries) GetFeedsOwnedByUser(ctx context.Context, userID uuid.NullUUID) ([]GetFeedsOwnedByUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedsOwnedByUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetFeedsOwnedByUserRow
+	for rows.Next() {
+		var i GetFeedsOwnedByUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.LastFetchError,
+			&i.NextFetchAt,
+			&i.OtherFollowers,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFeedsWithFetchErrors = `-- name: GetFeedsWithFetchErrors :many
+SELECT id, name, url, last_fetched_at, last_fetch_error, next_fetch_at
+FROM feeds
+WHERE last_fetch_error IS NOT NULL
+ORDER BY name
+`
+
+type GetFeedsWithFetchErrorsRow struct {
+	ID             uuid.UUID
+	Name           string
+	Url            string
+	LastFetchedAt  sql.NullTime
+	LastFetchError sql.NullString
+	NextFetchAt    sql.NullTime
+}
+
+func (q *Queries) GetFeedsWithFetchErrors(ctx context.Context) ([]GetFeedsWithFetchErrorsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedsWithFetchErrors)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetFeedsWithFetchErrorsRow
+	for rows.Next() {
+		var i GetFeedsWithFetchErrorsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Url,
+			&i.LastFetchedAt,
+			&i.LastFetchError,
+			&i.NextFetchAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markFeedFetched = `-- name: MarkFeedFetched :exec
+UPDATE feeds
+SET last_fetched_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) MarkFeedFetched(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, markFeedFetched, id)
+	return err
+}
+
+const resetFeeds = `-- name: ResetFeeds :exec
+DELETE FROM feeds
+`
+
+func (q *Queries) ResetFeeds(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, resetFeeds)
+	return err
+}
+
+const setFeedFetchResult = `-- name: SetFeedFetchResult :exec
+UPDATE feeds
+SET last_fetch_error = $2,
+    next_fetch_at = $3
+WHERE id = $1
+`
+
+type SetFeedFetchResultParams struct {
+	ID             uuid.UUID
+	LastFetchError sql.NullString
+	NextFetchAt    sql.NullTime
+}
+
+func (q *Queries) SetFeedFetchResult(ctx context.Context, arg SetFeedFetchResultParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedFetchResult, arg.ID, arg.LastFetchError, arg.NextFetchAt)
+	return err
+}
+
+const transferFeeds = `-- name: TransferFeeds :exec
+UPDATE feeds
+SET user_id = $1,
+    updated_at = NOW()
+WHERE user_id = $2
 `
 
-func (q *Queries) GetNextFeedToFetch(ctx context.Context) (Feed, error) {
-	row := q.db.QueryRowContext(ctx, getNextFeedToFetch)
+type TransferFeedsParams struct {
+	NewUserID uuid.NullUUID
+	OldUserID uuid.NullUUID
+}
+
+func (q *Queries) TransferFeeds(ctx context.Context, arg TransferFeedsParams) error {
+	_, err := q.db.ExecContext(ctx, transferFeeds, arg.NewUserID, arg.OldUserID)
+	return err
+}
+
+const updateFeedName = `-- name: UpdateFeedName :one
+UPDATE feeds
+SET name = $2,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, last_fetch_error, next_fetch_at
+`
+
+type UpdateFeedNameParams struct {
+	ID   uuid.UUID
+	Name string
+}
+
+func (q *Queries) UpdateFeedName(ctx context.Context, arg UpdateFeedNameParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, updateFeedName, arg.ID, arg.Name)
 	var i Feed
 	err := row.Scan(
 		&i.ID,
@@ -131,27 +403,68 @@ func (q *Queries) GetNextFeedToFetch(ctx context.Context) (Feed, error) {
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.LastFetchError,
+		&i.NextFetchAt,
 	)
 	return i, err
 }
 
-const markFeedFetched = `-- name: MarkFeedFetched :exec
+const updateFeedOwner = `-- name: UpdateFeedOwner :one
 UPDATE feeds
-SET last_fetched_at = NOW(),
+SET user_id = $2,
     updated_at = NOW()
 WHERE id = $1
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, last_fetch_error, next_fetch_at
 `
 
-func (q *Queries) MarkFeedFetched(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, markFeedFetched, id)
-	return err
+type UpdateFeedOwnerParams struct {
+	ID     uuid.UUID
+	UserID uuid.NullUUID
 }
 
-const resetFeeds = `-- name: ResetFeeds :exec
-DELETE FROM feeds
+func (q *Queries) UpdateFeedOwner(ctx context.Context, arg UpdateFeedOwnerParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, updateFeedOwner, arg.ID, arg.UserID)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.LastFetchError,
+		&i.NextFetchAt,
+	)
+	return i, err
+}
+
+const updateFeedURL = `-- name: UpdateFeedURL :one
+UPDATE feeds
+SET url = $2,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, last_fetch_error, next_fetch_at
 `
 
-func (q *Queries) ResetFeeds(ctx context.Context) error {
-	_, err := q.db.ExecContext(ctx, resetFeeds)
-	return err
+type UpdateFeedURLParams struct {
+	ID  uuid.UUID
+	Url string
+}
+
+func (q *Queries) UpdateFeedURL(ctx context.Context, arg UpdateFeedURLParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, updateFeedURL, arg.ID, arg.Url)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.LastFetchError,
+		&i.NextFetchAt,
+	)
+	return i, err
 }
@@ -0,0 +1,42 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// txBeginner is a DBTX that can start transactions, such as *sql.DB.
+type txBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// txWrapper is a DBTX that wraps the transactions it starts, such as one
+// tracing its statements, so that their statements get the same treatment.
+type txWrapper interface {
+	WrapTx(tx *sql.Tx) DBTX
+}
+
+// InTx runs fn with queries that all run in one transaction. The transaction
+// is committed if fn returns nil and rolled back otherwise.
+func (q *Queries) InTx(ctx context.Context, fn func(q *Queries) error) error {
+	beginner, ok := q.db.(txBeginner)
+	if !ok {
+		return errors.New("cannot start a transaction within a transaction")
+	}
+	tx, err := beginner.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	txQueries := q.WithTx(tx)
+	if wrapper, ok := q.db.(txWrapper); ok {
+		txQueries = New(wrapper.WrapTx(tx))
+	}
+
+	if err := fn(txQueries); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,138 @@
+package feedfetcher
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// fallbackCharset decodes any byte sequence, so it is the last resort for
+// feeds whose declared encodings do not match their content. It is also what
+// browsers use for content labelled ISO-8859-1.
+const fallbackCharset = "windows-1252"
+
+var (
+	utf8BOM    = []byte{0xEF, 0xBB, 0xBF}
+	utf16BEBOM = []byte{0xFE, 0xFF}
+	utf16LEBOM = []byte{0xFF, 0xFE}
+
+	xmlDeclarationEncoding = regexp.MustCompile(`^\s*<\?xml[^>]*?\sencoding\s*=\s*["']([A-Za-z0-9._:-]+)["']`)
+)
+
+// toUTF8 transcodes a feed to UTF-8 and returns the name of the encoding it
+// was found in.
+//
+// A byte order mark decides the encoding. Otherwise the charset of the
+// Content-Type header and the one of the XML declaration are tried, keeping
+// the first that decodes the content without errors. UTF-16 labels are only
+// believed from the header, for content that does look like UTF-16. Feeds often lie
+// about their encoding, so content that is valid UTF-8 is taken as UTF-8
+// whatever it claims, and content that no declared encoding can decode is
+// read as Windows-1252.
+func toUTF8(body []byte, contentType string) ([]byte, string, error) {
+	switch {
+	case bytes.HasPrefix(body, utf8BOM):
+		return body[len(utf8BOM):], "utf-8", nil
+	case bytes.HasPrefix(body, utf16BEBOM):
+		return decode(body, unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM), "utf-16be")
+	case bytes.HasPrefix(body, utf16LEBOM):
+		return decode(body, unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM), "utf-16le")
+	}
+
+	var labels []string
+	if _, params, err := mime.ParseMediaType(contentType); err == nil && params["charset"] != "" {
+		label := params["charset"]
+		// UTF-16 without a byte order mark can pass for UTF-8, so the label
+		// is trusted unless the content is UTF-8 without the NUL bytes that
+		// ASCII characters get in UTF-16.
+		if enc, name := lookupEncoding(label); enc != nil && strings.HasPrefix(name, "utf-16") {
+			if !utf8.Valid(body) || looksLikeUTF16(body) {
+				return decode(body, enc, name)
+			}
+		} else {
+			labels = append(labels, label)
+		}
+	}
+	// A declaration that could be read as ASCII cannot be in UTF-16, so a
+	// UTF-16 label there is a lie.
+	if match := xmlDeclarationEncoding.FindSubmatch(body[:min(len(body), 1024)]); match != nil {
+		if _, name := lookupEncoding(string(match[1])); !strings.HasPrefix(name, "utf-16") {
+			labels = append(labels, string(match[1]))
+		}
+	}
+
+	if utf8.Valid(body) {
+		return body, "utf-8", nil
+	}
+
+	// Single-byte encodings decode anything, so multi-byte ones, which fail
+	// on content that is not theirs, get the first chance.
+	var candidates []encoding.Encoding
+	var names []string
+	for _, singleByte := range []bool{false, true} {
+		for _, label := range labels {
+			enc, name := lookupEncoding(label)
+			if enc == nil || name == "utf-8" {
+				continue
+			}
+			if _, isCharmap := enc.(*charmap.Charmap); isCharmap == singleByte {
+				candidates = append(candidates, enc)
+				names = append(names, name)
+			}
+		}
+	}
+	for i, enc := range candidates {
+		decoded, name, err := decode(body, enc, names[i])
+		if err == nil && !bytes.ContainsRune(decoded, utf8.RuneError) {
+			return decoded, name, nil
+		}
+	}
+
+	enc, _ := lookupEncoding(fallbackCharset)
+	return decode(body, enc, fallbackCharset)
+}
+
+// looksLikeUTF16 reports whether the start of body has the NUL bytes that
+// ASCII characters, such as the markup of a feed, get in UTF-16.
+func looksLikeUTF16(body []byte) bool {
+	sample := body[:min(len(body), 512)]
+	return len(sample) >= 2 && bytes.Count(sample, []byte{0}) >= len(sample)/4
+}
+
+// lookupEncoding returns the encoding for a charset label following the
+// WHATWG Encoding Standard, along with its canonical name, or nil if the label
+// is unknown.
+func lookupEncoding(label string) (encoding.Encoding, string) {
+	enc, err := htmlindex.Get(strings.TrimSpace(label))
+	if err != nil {
+		return nil, ""
+	}
+	name, err := htmlindex.Name(enc)
+	if err != nil {
+		return nil, ""
+	}
+	return enc, name
+}
+
+func decode(body []byte, enc encoding.Encoding, name string) ([]byte, string, error) {
+	decoded, err := io.ReadAll(enc.NewDecoder().Reader(bytes.NewReader(body)))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode feed as %s: %v", name, err)
+	}
+	return decoded, name, nil
+}
+
+// utf8CharsetReader lets the XML decoder accept documents whose declaration
+// names another encoding after toUTF8 has already transcoded them.
+func utf8CharsetReader(label string, input io.Reader) (io.Reader, error) {
+	return input, nil
+}
@@ -0,0 +1,115 @@
+package feedfetcher
+
+import (
+	"testing"
+
+	"golang.org/x/text/encoding/unicode"
+)
+
+func utf16LE(t *testing.T, text string) []byte {
+	t.Helper()
+	encoded, err := unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewEncoder().Bytes([]byte(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encoded
+}
+
+func TestToUTF8(t *testing.T) {
+	const feed = `<?xml version="1.0"?><rss><channel><title>Café</title></channel></rss>`
+
+	tests := []struct {
+		name        string
+		body        []byte
+		contentType string
+		wantCharset string
+		want        string
+	}{
+		{
+			name:        "UTF-8",
+			body:        []byte(feed),
+			contentType: "application/rss+xml",
+			wantCharset: "utf-8",
+			want:        feed,
+		},
+		{
+			name:        "UTF-8 byte order mark",
+			body:        append([]byte{0xEF, 0xBB, 0xBF}, feed...),
+			contentType: "application/rss+xml; charset=iso-8859-1",
+			wantCharset: "utf-8",
+			want:        feed,
+		},
+		{
+			name:        "UTF-16 byte order mark",
+			body:        append([]byte{0xFF, 0xFE}, utf16LE(t, feed)...),
+			wantCharset: "utf-16le",
+			want:        feed,
+		},
+		{
+			name:        "UTF-16 header without byte order mark",
+			body:        utf16LE(t, feed),
+			contentType: "application/rss+xml; charset=utf-16",
+			wantCharset: "utf-16le",
+			want:        feed,
+		},
+		{
+			name:        "UTF-16 header on UTF-8 content",
+			body:        []byte(feed),
+			contentType: "application/rss+xml; charset=utf-16",
+			wantCharset: "utf-8",
+			want:        feed,
+		},
+		{
+			name:        "UTF-16 declaration on ASCII content",
+			body:        []byte(`<?xml version="1.0" encoding="UTF-16"?><rss><channel><title>Cafe</title></channel></rss>`),
+			wantCharset: "utf-8",
+			want:        `<?xml version="1.0" encoding="UTF-16"?><rss><channel><title>Cafe</title></channel></rss>`,
+		},
+		{
+			name:        "UTF-16 declaration on Latin-1 content",
+			body:        []byte("<?xml version=\"1.0\" encoding=\"UTF-16\"?><rss><channel><title>Caf\xe9</title></channel></rss>"),
+			wantCharset: "windows-1252",
+			want:        `<?xml version="1.0" encoding="UTF-16"?><rss><channel><title>Café</title></channel></rss>`,
+		},
+		{
+			name:        "Latin-1 declaration",
+			body:        []byte("<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><title>Caf\xe9</title>"),
+			wantCharset: "windows-1252",
+			want:        `<?xml version="1.0" encoding="ISO-8859-1"?><title>Café</title>`,
+		},
+		{
+			name:        "Latin-1 label on UTF-8 content",
+			body:        []byte(feed),
+			contentType: "text/xml; charset=iso-8859-1",
+			wantCharset: "utf-8",
+			want:        feed,
+		},
+		{
+			name:        "Shift JIS",
+			body:        []byte("<?xml version=\"1.0\" encoding=\"Shift_JIS\"?><title>\x93\xfa\x96\x7b</title>"),
+			wantCharset: "shift_jis",
+			want:        `<?xml version="1.0" encoding="Shift_JIS"?><title>日本</title>`,
+		},
+		{
+			name:        "undeclared and not UTF-8",
+			body:        []byte("<title>Caf\xe9</title>"),
+			wantCharset: "windows-1252",
+			want:        "<title>Café</title>",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, charset, err := toUTF8(test.body, test.contentType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if charset != test.wantCharset {
+				t.Errorf("charset = %q, want %q", charset, test.wantCharset)
+			}
+			if string(got) != test.want {
+				t.Errorf("toUTF8() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package feedfetcher
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ErrBodyTooLarge is returned when a response body exceeds the size limit
+// of the fetcher.
+var ErrBodyTooLarge = errors.New("response body too large")
+
+// HTTPStatusError is returned when the server answers with a status outside
+// the 2xx range.
+type HTTPStatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+	// RetryAt is when the server asked to be tried again through a
+	// Retry-After header on a 429 or 503 response, or zero.
+	RetryAt time.Time
+}
+
+func (err *HTTPStatusError) Error() string {
+	if !err.RetryAt.IsZero() {
+		return fmt.Sprintf("unexpected HTTP status %s from %s, retry after %s", err.Status, err.URL, err.RetryAt.Format(time.RFC1123))
+	}
+	return fmt.Sprintf("unexpected HTTP status %s from %s", err.Status, err.URL)
+}
+
+// ParseError is returned for feeds that could not be parsed, other than for
+// exceeding the parser limits.
+type ParseError struct {
+	Err error
+}
+
+func (err *ParseError) Error() string {
+	return err.Err.Error()
+}
+
+func (err *ParseError) Unwrap() error {
+	return err.Err
+}
+
+// limitedReader fails with ErrBodyTooLarge instead of silently stopping, as
+// io.LimitReader would, so that a truncated feed is not mistaken for a whole
+// one.
+type limitedReader struct {
+	reader    io.Reader
+	remaining int64
+}
+
+func (limited *limitedReader) Read(p []byte) (int, error) {
+	if limited.remaining <= 0 {
+		// Only fail if there is actually more to read.
+		var probe [1]byte
+		n, err := limited.reader.Read(probe[:])
+		if n > 0 {
+			return 0, ErrBodyTooLarge
+		}
+		return 0, err
+	}
+
+	if int64(len(p)) > limited.remaining {
+		p = p[:limited.remaining]
+	}
+	n, err := limited.reader.Read(p)
+	limited.remaining -= int64(n)
+	return n, err
+}
@@ -1,10 +1,39 @@
 package feedfetcher
 
 import (
+	"compress/gzip"
+	"compress/zlib"
 	"context"
-	"encoding/xml"
+	"errors"
+	"fmt"
 	"html"
+	"io"
+	"net"
 	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"time"
+
+	metrics "github.com/alancorleto/gator/internal/metrics"
+	tracing "github.com/alancorleto/gator/internal/tracing"
+	"github.com/andybalholm/brotli"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/http/httpproxy"
+)
+
+const (
+	DefaultConnectTimeout = 10 * time.Second
+	DefaultTimeout        = 30 * time.Second
+	DefaultMaxBodyBytes   = 10 << 20
+	DefaultContactURL     = "https://github.com/alancorleto/gator"
+
+	// maxRedirects matches the limit of the default http.Client.
+	maxRedirects = 10
+	// maxDrainBytes is how much of an unread body is discarded so that its
+	// connection can be reused; larger leftovers close the connection instead.
+	maxDrainBytes = 64 << 10
 )
 
 type RSSFeed struct {
@@ -23,26 +52,376 @@ type RSSItem struct {
 	PubDate     string `xml:"pubDate"`
 }
 
-func FetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
+// Redirect is a single hop of the redirect chain followed during a fetch.
+type Redirect struct {
+	From       string
+	To         string
+	StatusCode int
+}
+
+// Permanent reports whether the redirect says the resource moved for good.
+func (redirect Redirect) Permanent() bool {
+	return redirect.StatusCode == http.StatusMovedPermanently || redirect.StatusCode == http.StatusPermanentRedirect
+}
+
+type FetchResult struct {
+	Feed      *RSSFeed
+	Redirects []Redirect
+	// PermanentURL is where the feed permanently moved to, following the
+	// permanent redirects at the start of the chain, or empty if it did not
+	// move. A temporary redirect ends the chain, since the URL before it is
+	// the one that should be requested next time.
+	PermanentURL string
+	// Charset is the encoding the feed was transcoded from.
+	Charset string
+	// Warnings describes what was repaired or skipped in a malformed feed.
+	Warnings []string
+	// Retries is how many times the fetch was retried before it succeeded.
+	Retries int
+}
+
+// Options configures a Fetcher. Zero values select the defaults.
+type Options struct {
+	// ConnectTimeout bounds establishing the connection, TLS handshake
+	// included.
+	ConnectTimeout time.Duration
+	// Timeout bounds a whole fetch, from connecting to reading the last byte
+	// of the body, redirects included.
+	Timeout time.Duration
+	// MaxBodyBytes limits the size of the decompressed response body.
+	MaxBodyBytes int64
+	// UserAgent replaces the default User-Agent header entirely.
+	UserAgent string
+	// ContactURL is advertised in the default User-Agent so that feed
+	// publishers can reach whoever runs the aggregator.
+	ContactURL string
+	// StrictXML rejects feeds that are not well-formed XML instead of
+	// recovering what can be read from them.
+	StrictXML bool
+	// AllowedHosts lists host names, IP addresses and CIDR ranges exempt from
+	// the URL policy, such as a feed server on the local network.
+	AllowedHosts []string
+	// Limits bounds the resources parsing a feed may take.
+	Limits Limits
+	// HostInterval is the average time between two requests to the same host,
+	// once HostBurst requests have been made in a row.
+	HostInterval time.Duration
+	// HostBurst is how many requests to the same host may be made in a row.
+	HostBurst int
+	// HostConcurrency is how many requests to the same host may run at once.
+	HostConcurrency int
+	// MaxRetryAfter caps how long a Retry-After header may hold off a host.
+	MaxRetryAfter time.Duration
+	// MaxRetries is how many times a fetch that failed for a transient reason
+	// is retried. A negative value disables retries.
+	MaxRetries int
+	// RetryBudget bounds a whole fetch, retries and the waits between them
+	// included.
+	RetryBudget time.Duration
+}
+
+// Fetcher downloads feeds over a shared HTTP client, so connections to the
+// same host are reused between fetches, and paces the requests it makes to
+// each host. It is safe for concurrent use.
+type Fetcher struct {
+	client        *http.Client
+	policy        *URLPolicy
+	hosts         *hostLimiter
+	userAgent     string
+	maxBodyBytes  int64
+	strictXML     bool
+	limits        Limits
+	maxRetryAfter time.Duration
+	maxRetries    int
+	retryBudget   time.Duration
+}
+
+type redirectsKey struct{}
+
+func NewFetcher(options Options) (*Fetcher, error) {
+	if options.ConnectTimeout <= 0 {
+		options.ConnectTimeout = DefaultConnectTimeout
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = DefaultTimeout
+	}
+	if options.MaxBodyBytes <= 0 {
+		options.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+	if options.ContactURL == "" {
+		options.ContactURL = DefaultContactURL
+	}
+	if options.HostInterval <= 0 {
+		options.HostInterval = DefaultHostInterval
+	}
+	if options.HostBurst <= 0 {
+		options.HostBurst = DefaultHostBurst
+	}
+	if options.HostConcurrency <= 0 {
+		options.HostConcurrency = DefaultHostConcurrency
+	}
+	if options.MaxRetryAfter <= 0 {
+		options.MaxRetryAfter = DefaultMaxRetryAfter
+	}
+	if options.MaxRetries == 0 {
+		options.MaxRetries = DefaultMaxRetries
+	} else if options.MaxRetries < 0 {
+		options.MaxRetries = 0
+	}
+	if options.RetryBudget <= 0 {
+		options.RetryBudget = DefaultRetryBudget
+	}
+	if options.UserAgent == "" {
+		options.UserAgent = fmt.Sprintf("gator/1.0 (feed aggregator; +%s)", options.ContactURL)
+	}
+
+	policy, err := NewURLPolicy(options.AllowedHosts)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("User-Agent", "gator")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	dialer := &net.Dialer{
+		Timeout:   options.ConnectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	proxyConfig := httpproxy.FromEnvironment()
+	proxies := proxyAddrs(proxyConfig)
+	transport := &http.Transport{
+		Proxy:               policy.proxy(proxyConfig, proxies),
+		DialContext:         policy.dialContext(dialer, proxies),
+		TLSHandshakeTimeout: options.ConnectTimeout,
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 4,
+		IdleConnTimeout:     90 * time.Second,
+		ForceAttemptHTTP2:   true,
+		// Compression is negotiated in FetchFeed to add brotli to gzip.
+		DisableCompression: true,
+	}
+
+	fetcher := &Fetcher{
+		policy:        policy,
+		hosts:         newHostLimiter(options.HostInterval, options.HostBurst, options.HostConcurrency),
+		userAgent:     options.UserAgent,
+		maxBodyBytes:  options.MaxBodyBytes,
+		strictXML:     options.StrictXML,
+		limits:        options.Limits.withDefaults(),
+		maxRetryAfter: options.MaxRetryAfter,
+		maxRetries:    options.MaxRetries,
+		retryBudget:   options.RetryBudget,
+	}
+	fetcher.client = &http.Client{
+		Transport:     transport,
+		Timeout:       options.Timeout,
+		CheckRedirect: fetcher.checkRedirect,
+	}
+	return fetcher, nil
+}
+
+// CheckURL reports whether the URL policy allows fetching rawURL, resolving
+// its host. It lets commands reject a feed URL before storing it.
+func (fetcher *Fetcher) CheckURL(ctx context.Context, rawURL string) error {
+	return fetcher.policy.CheckURL(ctx, rawURL)
+}
+
+// checkRedirect applies the URL policy to each redirect and appends it to the
+// slice stored in the context of the request by FetchFeed.
+func (fetcher *Fetcher) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	if _, err := fetcher.policy.checkScheme(req.URL.String()); err != nil {
+		return err
+	}
+	if redirects, ok := req.Context().Value(redirectsKey{}).(*[]Redirect); ok {
+		*redirects = append(*redirects, Redirect{
+			From:       via[len(via)-1].URL.String(),
+			To:         req.URL.String(),
+			StatusCode: req.Response.StatusCode,
+		})
+	}
+	return nil
+}
+
+// FetchFeed downloads and parses the feed at feedURL. A response outside the
+// 2xx range fails with an *HTTPStatusError, and a body larger than the
+// configured limit with ErrBodyTooLarge, a URL refused by the URL policy,
+// redirects included, with an *URLNotAllowedError, and a feed exceeding the
+// parser limits with a *LimitError. Malformed feeds are parsed leniently
+// unless the fetcher is strict, see parseFeed.
+//
+// FetchFeed waits its turn to request the host of feedURL; redirects are not
+// paced separately. A host that answered with Retry-After is not requested
+// again before the time it gave, fetches from it failing with a
+// *HostBackoffError in the meantime. RetryAt tells when to try again.
+//
+// Fetches failing for a transient reason, see Transient, are retried with a
+// growing, jittered delay as long as the retry budget allows. The error of a
+// fetch that was retried in vain is a *RetriedError wrapping the last one.
+//
+// FetchFeed is traced in a span, with a child span for each wait for the
+// host, request and parse of the feed.
+func (fetcher *Fetcher) FetchFeed(ctx context.Context, feedURL string) (*FetchResult, error) {
+	ctx, span := tracer.Start(ctx, "FetchFeed", trace.WithAttributes(semconv.URLFull(feedURL)))
+	start := time.Now()
+	result, err := fetcher.fetchWithRetries(ctx, feedURL)
+	outcome := fetchOutcome(err)
+	metrics.FetchDuration.Observe(time.Since(start).Seconds())
+	metrics.Fetches.WithLabelValues(outcome).Inc()
+	span.SetAttributes(attribute.String("gator.fetch.outcome", outcome))
+	tracing.End(span, err)
+	return result, err
+}
+
+func (fetcher *Fetcher) fetchWithRetries(ctx context.Context, feedURL string) (*FetchResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, fetcher.retryBudget)
+	defer cancel()
+
+	retries := 0
+	for {
+		result, err := fetcher.fetchOnce(ctx, feedURL)
+		if err == nil {
+			result.Retries = retries
+			return result, nil
+		}
+
+		// Give up on permanent errors, after the last retry, or when the next
+		// attempt could not start within the budget.
+		delay := retryDelay(retries + 1)
+		deadline, _ := ctx.Deadline()
+		if retries >= fetcher.maxRetries || !Transient(err) || time.Until(deadline) < delay || sleep(ctx, delay) != nil {
+			if retries == 0 {
+				return nil, err
+			}
+			return nil, &RetriedError{Retries: retries, Err: err}
+		}
+		retries++
+		metrics.FetchRetries.Inc()
+		trace.SpanFromContext(ctx).AddEvent("retry", trace.WithAttributes(
+			attribute.Int("retry", retries),
+			attribute.String("delay", delay.String()),
+			attribute.String("error", err.Error()),
+		))
+	}
+}
+
+// fetchOnce makes a single attempt at FetchFeed.
+func (fetcher *Fetcher) fetchOnce(ctx context.Context, feedURL string) (*FetchResult, error) {
+	parsedUrl, err := fetcher.policy.checkScheme(feedURL)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	var feed RSSFeed
-	decoder := xml.NewDecoder(resp.Body)
-	err = decoder.Decode(&feed)
+	waitCtx, span := tracer.Start(ctx, "wait for host", trace.WithAttributes(semconv.ServerAddress(parsedUrl.Hostname())))
+	release, err := fetcher.hosts.acquire(waitCtx, parsedUrl.Hostname())
+	tracing.End(span, err)
 	if err != nil {
 		return nil, err
 	}
+	defer release()
+
+	result := &FetchResult{}
+	content, contentType, err := fetcher.download(ctx, feedURL, &result.Redirects)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, redirect := range result.Redirects {
+		if !redirect.Permanent() {
+			break
+		}
+		result.PermanentURL = redirect.To
+	}
+
+	err = fetcher.parse(ctx, content, contentType, result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// download requests feedURL and returns the body of the response, decoded
+// but not parsed, and its content type. The redirects followed are appended
+// to redirects.
+func (fetcher *Fetcher) download(ctx context.Context, feedURL string, redirects *[]Redirect) (content []byte, contentType string, err error) {
+	ctx, span := tracer.Start(ctx, "GET",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.HTTPRequestMethodGet, semconv.URLFull(feedURL)),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	ctx = context.WithValue(ctx, redirectsKey{}, redirects)
+	ctx = httptrace.WithClientTrace(ctx, clientTrace(span))
+	req, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("User-Agent", fetcher.userAgent)
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/xml;q=0.9, text/xml;q=0.9, */*;q=0.8")
+	req.Header.Set("Accept-Encoding", "gzip, br, deflate")
+
+	resp, err := fetcher.client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	metrics.HTTPResponses.WithLabelValues(statusClass(resp.StatusCode)).Inc()
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	countedBody := &countingBody{ReadCloser: resp.Body}
+	resp.Body = countedBody
+	defer func() {
+		io.CopyN(io.Discard, resp.Body, maxDrainBytes)
+		resp.Body.Close()
+		span.SetAttributes(semconv.HTTPResponseBodySize(countedBody.read))
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		statusErr := &HTTPStatusError{
+			URL:        resp.Request.URL.String(),
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+		}
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+			if retryAt, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now(), fetcher.maxRetryAfter); ok {
+				statusErr.RetryAt = retryAt
+				fetcher.hosts.backOff(resp.Request.URL.Hostname(), retryAt)
+			}
+		}
+		return nil, "", statusErr
+	}
+
+	body, err := decodeBody(resp)
+	if err != nil {
+		return nil, "", err
+	}
+	content, err = io.ReadAll(&limitedReader{reader: body, remaining: fetcher.maxBodyBytes})
+	if err != nil {
+		return nil, "", err
+	}
+	return content, resp.Header.Get("Content-Type"), nil
+}
+
+// parse converts content to UTF-8 and parses it into result.
+func (fetcher *Fetcher) parse(ctx context.Context, content []byte, contentType string, result *FetchResult) (err error) {
+	_, span := tracer.Start(ctx, "parse feed", trace.WithAttributes(attribute.Int("gator.feed.bytes", len(content))))
+	defer func() { tracing.End(span, err) }()
+
+	content, result.Charset, err = toUTF8(content, contentType)
+	if err != nil {
+		return err
+	}
+
+	feed, warnings, err := parseFeed(content, fetcher.strictXML, fetcher.limits)
+	result.Warnings = warnings
+	var limitErr *LimitError
+	switch {
+	case errors.As(err, &limitErr):
+		metrics.ParseErrors.WithLabelValues("limit").Inc()
+		return err
+	case err != nil:
+		metrics.ParseErrors.WithLabelValues("malformed").Inc()
+		return &ParseError{Err: err}
+	case len(warnings) > 0:
+		metrics.LenientParses.Inc()
+	}
 
 	feed.Channel.Title = html.UnescapeString(feed.Channel.Title)
 	feed.Channel.Description = html.UnescapeString(feed.Channel.Description)
@@ -52,5 +431,35 @@ func FetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
 		feed.Channel.Item[i].Description = html.UnescapeString(feed.Channel.Item[i].Description)
 	}
 
-	return &feed, nil
+	span.SetAttributes(
+		attribute.String("gator.feed.charset", result.Charset),
+		attribute.Int("gator.feed.items", len(feed.Channel.Item)),
+		attribute.Int("gator.feed.warnings", len(warnings)),
+	)
+	result.Feed = feed
+	return nil
+}
+
+// decodeBody undoes the Content-Encoding of the response.
+func decodeBody(resp *http.Response) (io.Reader, error) {
+	switch encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))); encoding {
+	case "", "identity":
+		return resp.Body, nil
+	case "gzip", "x-gzip":
+		reader, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip body: %v", err)
+		}
+		return reader, nil
+	case "br":
+		return brotli.NewReader(resp.Body), nil
+	case "deflate":
+		reader, err := zlib.NewReader(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid deflate body: %v", err)
+		}
+		return reader, nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
 }
@@ -0,0 +1,106 @@
+package feedfetcher
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+)
+
+const (
+	DefaultMaxDepth      = 64
+	DefaultMaxItems      = 5000
+	DefaultMaxFieldBytes = 1 << 20
+)
+
+// Limits bounds the resources a single feed may take to parse.
+type Limits struct {
+	// MaxDepth is how deeply elements may nest.
+	MaxDepth int
+	// MaxItems is how many items a feed may hold.
+	MaxItems int
+	// MaxFieldBytes is how much text a single element may hold.
+	MaxFieldBytes int
+}
+
+// LimitError is returned for feeds that exceed one of the parser limits or
+// try tricks such as entity declarations.
+type LimitError struct {
+	Limit string
+	Max   int
+}
+
+func (err *LimitError) Error() string {
+	if err.Max == 0 {
+		return fmt.Sprintf("feed rejected: %s", err.Limit)
+	}
+	return fmt.Sprintf("feed exceeds the limit of %d %s", err.Max, err.Limit)
+}
+
+func (limits Limits) withDefaults() Limits {
+	if limits.MaxDepth <= 0 {
+		limits.MaxDepth = DefaultMaxDepth
+	}
+	if limits.MaxItems <= 0 {
+		limits.MaxItems = DefaultMaxItems
+	}
+	if limits.MaxFieldBytes <= 0 {
+		limits.MaxFieldBytes = DefaultMaxFieldBytes
+	}
+	return limits
+}
+
+// checkLimits scans a document before it is decoded into memory and returns a
+// *LimitError if it nests too deeply, holds too many items or too much text
+// in one element, or declares entities. encoding/xml does not expand entities
+// declared in a DTD, but such declarations have no business in a feed and
+// are the usual vehicle of expansion attacks, so they are refused outright.
+//
+// The scan is as forgiving as the lenient parser; a syntax error ends it
+// without error, leaving the parser to report or recover from it.
+func checkLimits(content []byte, limits Limits) error {
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	decoder.CharsetReader = utf8CharsetReader
+	decoder.Strict = false
+	decoder.AutoClose = voidElements
+	decoder.Entity = xml.HTMLEntity
+
+	// fieldSizes holds the text gathered so far by each open element.
+	var fieldSizes []int
+	items := 0
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			// Either the end of the document or a syntax error.
+			return nil
+		}
+
+		switch element := token.(type) {
+		case xml.StartElement:
+			fieldSizes = append(fieldSizes, 0)
+			if len(fieldSizes) > limits.MaxDepth {
+				return &LimitError{Limit: "levels of nested elements", Max: limits.MaxDepth}
+			}
+			if element.Name.Local == "item" || element.Name.Local == "entry" {
+				items++
+				if items > limits.MaxItems {
+					return &LimitError{Limit: "items", Max: limits.MaxItems}
+				}
+			}
+		case xml.EndElement:
+			if len(fieldSizes) > 0 {
+				fieldSizes = fieldSizes[:len(fieldSizes)-1]
+			}
+		case xml.CharData:
+			if len(fieldSizes) > 0 {
+				fieldSizes[len(fieldSizes)-1] += len(element)
+				if fieldSizes[len(fieldSizes)-1] > limits.MaxFieldBytes {
+					return &LimitError{Limit: "bytes of text in an element", Max: limits.MaxFieldBytes}
+				}
+			}
+		case xml.Directive:
+			if bytes.Contains(element, []byte("ENTITY")) {
+				return &LimitError{Limit: "the document declares entities"}
+			}
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package feedfetcher
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// feedWithItems returns a feed of count items whose titles hold title.
+func feedWithItems(count int, title string) string {
+	return `<rss version="2.0"><channel><title>t</title>` +
+		strings.Repeat("<item><title>"+title+"</title></item>", count) +
+		`</channel></rss>`
+}
+
+// nested returns depth elements nested in each other.
+func nested(depth int) string {
+	return strings.Repeat("<a>", depth) + "x" + strings.Repeat("</a>", depth)
+}
+
+func TestCheckLimits(t *testing.T) {
+	limits := Limits{MaxDepth: 10, MaxItems: 5, MaxFieldBytes: 100}
+
+	tests := []struct {
+		name      string
+		content   string
+		wantLimit string
+	}{
+		{
+			name:    "within limits",
+			content: feedWithItems(5, strings.Repeat("x", 100)),
+		},
+		{
+			name:      "entity declaration",
+			content:   `<?xml version="1.0"?><!DOCTYPE rss [<!ENTITY lol "lol">]><rss><channel><title>&lol;</title></channel></rss>`,
+			wantLimit: "the document declares entities",
+		},
+		{
+			name:      "billion laughs",
+			content:   `<?xml version="1.0"?><!DOCTYPE lolz [<!ENTITY lol "lol"><!ENTITY lol2 "&lol;&lol;&lol;&lol;">]><lolz>&lol2;</lolz>`,
+			wantLimit: "the document declares entities",
+		},
+		{
+			name:    "depth at the limit",
+			content: nested(10),
+		},
+		{
+			name:      "depth just over the limit",
+			content:   nested(11),
+			wantLimit: "levels of nested elements",
+		},
+		{
+			name:      "depth over the limit in unclosed elements",
+			content:   strings.Repeat("<a>", 11),
+			wantLimit: "levels of nested elements",
+		},
+		{
+			name:      "items over the limit",
+			content:   feedWithItems(6, "x"),
+			wantLimit: "items",
+		},
+		{
+			name:      "Atom entries over the limit",
+			content:   `<feed>` + strings.Repeat("<entry><title>x</title></entry>", 6) + `</feed>`,
+			wantLimit: "items",
+		},
+		{
+			name:      "oversized field",
+			content:   feedWithItems(1, strings.Repeat("x", 101)),
+			wantLimit: "bytes of text in an element",
+		},
+		{
+			name:      "oversized field in CDATA pieces",
+			content:   feedWithItems(1, strings.Repeat("<![CDATA[xxxxxxxxxx]]>", 11)),
+			wantLimit: "bytes of text in an element",
+		},
+		{
+			name:    "syntax error",
+			content: `<rss><channel><title>a & b</title></channel></rss>`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := checkLimits([]byte(test.content), limits)
+			if test.wantLimit == "" {
+				if err != nil {
+					t.Fatalf("checkLimits() = %v, want nil", err)
+				}
+				return
+			}
+
+			var limitErr *LimitError
+			if !errors.As(err, &limitErr) {
+				t.Fatalf("checkLimits() = %v, want a *LimitError", err)
+			}
+			if limitErr.Limit != test.wantLimit {
+				t.Errorf("limit = %q, want %q", limitErr.Limit, test.wantLimit)
+			}
+		})
+	}
+}
+
+func TestParseFeedLimits(t *testing.T) {
+	limits := Limits{MaxItems: 2}.withDefaults()
+
+	// The lenient parser must not get around the limits either.
+	for _, content := range []string{feedWithItems(3, "x"), feedWithItems(3, "a & b")} {
+		for _, strict := range []bool{false, true} {
+			_, _, err := parseFeed([]byte(content), strict, limits)
+			var limitErr *LimitError
+			if !errors.As(err, &limitErr) {
+				t.Errorf("parseFeed(%q, strict %v) = %v, want a *LimitError", content, strict, err)
+			}
+		}
+	}
+}
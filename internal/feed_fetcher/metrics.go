@@ -0,0 +1,63 @@
+package feedfetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+
+	metrics "github.com/alancorleto/gator/internal/metrics"
+)
+
+// countingBody counts the bytes read from a response body as downloaded.
+type countingBody struct {
+	io.ReadCloser
+	read int
+}
+
+func (body *countingBody) Read(p []byte) (int, error) {
+	n, err := body.ReadCloser.Read(p)
+	body.read += n
+	metrics.DownloadedBytes.Add(float64(n))
+	return n, err
+}
+
+// statusClass returns the class of an HTTP status code, such as "4xx".
+func statusClass(statusCode int) string {
+	if statusCode < 100 || statusCode > 599 {
+		return "other"
+	}
+	return fmt.Sprintf("%dxx", statusCode/100)
+}
+
+// fetchOutcome sums up how a fetch ended for the fetches metric.
+func fetchOutcome(err error) string {
+	var statusErr *HTTPStatusError
+	var notAllowedErr *URLNotAllowedError
+	var backoffErr *HostBackoffError
+	var limitErr *LimitError
+	var parseErr *ParseError
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return "success"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.As(err, &statusErr):
+		return "http_error"
+	case errors.As(err, &notAllowedErr):
+		return "not_allowed"
+	case errors.As(err, &backoffErr):
+		return "backoff"
+	case errors.As(err, &limitErr), errors.As(err, &parseErr):
+		return "parse_error"
+	case errors.Is(err, ErrBodyTooLarge):
+		return "too_large"
+	case errors.As(err, &netErr) && netErr.Timeout(), errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.As(err, &netErr):
+		return "network_error"
+	}
+	return "other_error"
+}
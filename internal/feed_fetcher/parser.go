@@ -0,0 +1,206 @@
+package feedfetcher
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+)
+
+var (
+	entityReference = regexp.MustCompile(`^&(#[0-9]+|#[xX][0-9a-fA-F]+|[A-Za-z][A-Za-z0-9]*);`)
+	rootEnd         = regexp.MustCompile(`(?i)</(rss|rdf:RDF|feed)\s*>`)
+
+	// voidElements are the HTML elements without an end tag that show up
+	// unescaped in descriptions. Unlike xml.HTMLAutoClose it leaves out
+	// "link", which is a regular element in feeds.
+	voidElements = []string{"br", "hr", "img", "input", "meta", "area", "col", "param", "base", "basefont", "frame", "isindex"}
+)
+
+// parseFeed decodes a feed that is already in UTF-8, once checkLimits has
+// accepted it. Documents that are not well-formed XML fail in strict mode.
+// Otherwise they go through preClean and a forgiving decoder that keeps every
+// item read before an unrecoverable error; what had to be fixed or given up
+// on is returned as warnings.
+func parseFeed(content []byte, strict bool, limits Limits) (*RSSFeed, []string, error) {
+	if err := checkLimits(content, limits); err != nil {
+		return nil, nil, err
+	}
+
+	var feed RSSFeed
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	decoder.CharsetReader = utf8CharsetReader
+	err := decoder.Decode(&feed)
+	if err == nil {
+		return &feed, nil, nil
+	}
+	if strict {
+		return nil, nil, err
+	}
+
+	warnings := []string{fmt.Sprintf("feed is not well-formed XML (%v), parsed leniently", err)}
+	content, cleanWarnings := preClean(content)
+	warnings = append(warnings, cleanWarnings...)
+	// Cleaning may let the scan get further than it did.
+	if err := checkLimits(content, limits); err != nil {
+		return nil, warnings, err
+	}
+
+	lenientFeed, decodeWarnings, err := decodeLenient(content)
+	warnings = append(warnings, decodeWarnings...)
+	if err != nil {
+		return nil, warnings, err
+	}
+	return lenientFeed, warnings, nil
+}
+
+// preClean repairs the most common ways feeds break XML: garbage around the
+// document, bare ampersands and control characters XML does not allow.
+func preClean(content []byte) ([]byte, []string) {
+	var warnings []string
+
+	if start := bytes.IndexByte(content, '<'); start > 0 {
+		if len(bytes.TrimSpace(content[:start])) > 0 {
+			warnings = append(warnings, fmt.Sprintf("removed %d bytes before the document", start))
+		}
+		content = content[start:]
+	}
+
+	if matches := rootEnd.FindAllIndex(content, -1); matches != nil {
+		end := matches[len(matches)-1][1]
+		if trailing := bytes.TrimSpace(content[end:]); len(trailing) > 0 {
+			warnings = append(warnings, fmt.Sprintf("removed %d bytes after the document", len(content)-end))
+		}
+		content = content[:end]
+	}
+
+	cleaned := make([]byte, 0, len(content))
+	ampersands, controls := 0, 0
+	for i := 0; i < len(content); i++ {
+		// Markup that may legitimately contain anything is copied as is.
+		if skip := verbatimSection(content[i:]); skip > 0 {
+			cleaned = append(cleaned, content[i:i+skip]...)
+			i += skip - 1
+			continue
+		}
+
+		switch char := content[i]; {
+		case char == '&' && !entityReference.Match(content[i:]):
+			cleaned = append(cleaned, "&amp;"...)
+			ampersands++
+		case char < 0x20 && char != '\t' && char != '\n' && char != '\r':
+			controls++
+		default:
+			cleaned = append(cleaned, char)
+		}
+	}
+
+	if ampersands > 0 {
+		warnings = append(warnings, fmt.Sprintf("escaped %d bare ampersand(s)", ampersands))
+	}
+	if controls > 0 {
+		warnings = append(warnings, fmt.Sprintf("removed %d control character(s)", controls))
+	}
+	return cleaned, warnings
+}
+
+// verbatimSection returns the length of the CDATA section or comment content
+// starts with, or 0.
+func verbatimSection(content []byte) int {
+	for _, delimiters := range [][2]string{{"<![CDATA[", "]]>"}, {"<!--", "-->"}} {
+		if !bytes.HasPrefix(content, []byte(delimiters[0])) {
+			continue
+		}
+		end := bytes.Index(content[len(delimiters[0]):], []byte(delimiters[1]))
+		if end < 0 {
+			return len(content)
+		}
+		return len(delimiters[0]) + end + len(delimiters[1])
+	}
+	return 0
+}
+
+// decodeLenient walks the document token by token with a non-strict decoder
+// that knows HTML entities, collecting the channel fields and every item it
+// reaches. Decoding stops at the first error the decoder cannot get past, and
+// only fails if nothing at all could be read.
+func decodeLenient(content []byte) (*RSSFeed, []string, error) {
+	var warnings []string
+	var feed RSSFeed
+
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	decoder.CharsetReader = utf8CharsetReader
+	decoder.Strict = false
+	decoder.AutoClose = voidElements
+	decoder.Entity = xml.HTMLEntity
+
+	depth, channelDepth := 0, -1
+	foundElement := false
+	var parseErr error
+	for parseErr == nil {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			parseErr = err
+			break
+		}
+
+		switch element := token.(type) {
+		case xml.StartElement:
+			foundElement = true
+			switch {
+			case element.Name.Local == "channel" && channelDepth < 0:
+				channelDepth = depth
+			case element.Name.Local == "item":
+				var item RSSItem
+				if err := decoder.DecodeElement(&item, &element); err != nil {
+					parseErr = err
+					continue
+				}
+				feed.Channel.Item = append(feed.Channel.Item, item)
+				continue
+			case depth == channelDepth+1 && element.Name.Space == "":
+				if field := channelField(&feed, element.Name.Local); field != nil {
+					if err := decoder.DecodeElement(field, &element); err != nil {
+						parseErr = err
+					}
+					continue
+				}
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+			if depth == channelDepth {
+				channelDepth = -1
+			}
+		}
+	}
+
+	if parseErr != nil {
+		line, _ := decoder.InputPos()
+		if len(feed.Channel.Item) == 0 && feed.Channel.Title == "" {
+			return nil, warnings, fmt.Errorf("failed to parse feed: %v", parseErr)
+		}
+		warnings = append(warnings, fmt.Sprintf("stopped parsing at line %d (%v), kept %d item(s)", line, parseErr, len(feed.Channel.Item)))
+	}
+	if !foundElement {
+		return nil, warnings, fmt.Errorf("failed to parse feed: no XML elements found")
+	}
+	return &feed, warnings, nil
+}
+
+func channelField(feed *RSSFeed, name string) *string {
+	switch name {
+	case "title":
+		return &feed.Channel.Title
+	case "link":
+		return &feed.Channel.Link
+	case "description":
+		return &feed.Channel.Description
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package feedfetcher
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseFeedLenient(t *testing.T) {
+	tests := []struct {
+		name         string
+		content      string
+		wantTitle    string
+		wantItems    []string
+		wantWarnings []string
+	}{
+		{
+			name:      "well-formed",
+			content:   `<rss><channel><title>T</title><item><title>a</title></item></channel></rss>`,
+			wantTitle: "T",
+			wantItems: []string{"a"},
+		},
+		{
+			name:      "byte order mark",
+			content:   "\xef\xbb\xbf<rss><channel><title>T</title><item><title>a</title></item></channel></rss>",
+			wantTitle: "T",
+			wantItems: []string{"a"},
+		},
+		{
+			name:      "garbage before the document",
+			content:   "Warning: cache miss\n<rss><channel><title>T</title><item><title>a</title></item></channel></rss>",
+			wantTitle: "T",
+			wantItems: []string{"a"},
+		},
+		{
+			name:      "garbage after the document",
+			content:   "<rss><channel><title>T</title><item><title>a</title></item></channel></rss>\n<!-- served by cache -->garbage <b>",
+			wantTitle: "T",
+			wantItems: []string{"a"},
+		},
+		{
+			name:         "garbage around a broken document",
+			content:      "junk<rss><channel><title>Tom & Jerry</title></channel></rss>junk",
+			wantTitle:    "Tom & Jerry",
+			wantWarnings: []string{"parsed leniently", "removed 4 bytes before the document", "removed 4 bytes after the document", "escaped 1 bare ampersand(s)"},
+		},
+		{
+			name:         "bare ampersands",
+			content:      `<rss><channel><title>Tom & Jerry</title><item><title>a &amp; b & c</title></item></channel></rss>`,
+			wantTitle:    "Tom & Jerry",
+			wantItems:    []string{"a & b & c"},
+			wantWarnings: []string{"parsed leniently", "escaped 2 bare ampersand(s)"},
+		},
+		{
+			name:         "HTML entities",
+			content:      `<rss><channel><title>A&nbsp;B</title><item><title>caf&eacute;&nbsp;&#8212;&#x2014;</title></item></channel></rss>`,
+			wantTitle:    "A B",
+			wantItems:    []string{"café ——"},
+			wantWarnings: []string{"parsed leniently"},
+		},
+		{
+			name:         "control characters",
+			content:      "<rss><channel><title>T\x01</title><item><title>a\x0b</title></item></channel></rss>",
+			wantTitle:    "T",
+			wantItems:    []string{"a"},
+			wantWarnings: []string{"parsed leniently", "removed 2 control character(s)"},
+		},
+		{
+			name:         "ampersand in CDATA left alone",
+			content:      `<rss><channel><title>T & U</title><item><title><![CDATA[a & b]]></title></item></channel></rss>`,
+			wantTitle:    "T & U",
+			wantItems:    []string{"a & b"},
+			wantWarnings: []string{"parsed leniently", "escaped 1 bare ampersand(s)"},
+		},
+		{
+			name:         "unclosed HTML in a description",
+			content:      `<rss><channel><title>T</title><item><title>a</title></item><item><title>b</title><description><p>unclosed</description></item><item><title>c</title></item></channel></rss>`,
+			wantTitle:    "T",
+			wantItems:    []string{"a", "b", "c"},
+			wantWarnings: []string{"parsed leniently"},
+		},
+		{
+			name:         "broken item in the middle",
+			content:      `<rss><channel><title>T</title><item><title>a</title></item><item><title>b<</title></item><item><title>c</title></item></channel></rss>`,
+			wantTitle:    "T",
+			wantItems:    []string{"a"},
+			wantWarnings: []string{"parsed leniently", "stopped parsing at line 1", "kept 1 item(s)"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			feed, warnings, err := parseFeed([]byte(test.content), false, Limits{}.withDefaults())
+			if err != nil {
+				t.Fatalf("parseFeed() failed: %v", err)
+			}
+			if feed.Channel.Title != test.wantTitle {
+				t.Errorf("title = %q, want %q", feed.Channel.Title, test.wantTitle)
+			}
+			var items []string
+			for _, item := range feed.Channel.Item {
+				items = append(items, item.Title)
+			}
+			if strings.Join(items, "|") != strings.Join(test.wantItems, "|") {
+				t.Errorf("items = %q, want %q", items, test.wantItems)
+			}
+
+			allWarnings := strings.Join(warnings, "\n")
+			for _, want := range test.wantWarnings {
+				if !strings.Contains(allWarnings, want) {
+					t.Errorf("warnings %q do not mention %q", warnings, want)
+				}
+			}
+			if len(test.wantWarnings) == 0 && len(warnings) > 0 {
+				t.Errorf("warnings = %q, want none", warnings)
+			}
+		})
+	}
+}
+
+func TestParseFeedStrict(t *testing.T) {
+	_, _, err := parseFeed([]byte(`<rss><channel><title>Tom & Jerry</title></channel></rss>`), true, Limits{}.withDefaults())
+	if err == nil {
+		t.Error("strict parseFeed accepted a bare ampersand")
+	}
+}
+
+func TestParseFeedUnreadable(t *testing.T) {
+	for _, content := range []string{"", "not a feed", "<rss><<<"} {
+		if _, _, err := parseFeed([]byte(content), false, Limits{}.withDefaults()); err == nil {
+			t.Errorf("parseFeed(%q) succeeded", content)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package feedfetcher
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// blockedPrefixes are special-purpose ranges not covered by the netip.Addr
+// predicates used in checkAddr.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "this network"
+	netip.MustParsePrefix("100.64.0.0/10"),   // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved, broadcast included
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64, may embed any IPv4 address
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // local-use IPv4/IPv6 translation
+	netip.MustParsePrefix("2001:db8::/32"),   // documentation
+	netip.MustParsePrefix("fec0::/10"),       // deprecated site-local
+	netip.MustParsePrefix("::ffff:0:0:0/96"), // IPv4-translated
+}
+
+// URLNotAllowedError is returned for URLs the URL policy refuses to fetch.
+type URLNotAllowedError struct {
+	URL    string
+	Reason string
+}
+
+func (err *URLNotAllowedError) Error() string {
+	return fmt.Sprintf("fetching %s is not allowed: %s", err.URL, err.Reason)
+}
+
+// URLPolicy decides which URLs may be fetched, so that feed URLs supplied by
+// users cannot reach the machine gator runs on or its internal network. Only
+// http and https are allowed, and connections to loopback, private,
+// link-local and other special-purpose addresses are refused unless the host
+// or address is in the allow-list.
+type URLPolicy struct {
+	allowedHosts    map[string]bool
+	allowedPrefixes []netip.Prefix
+}
+
+// NewURLPolicy builds a policy whose allow-list holds host names, IP
+// addresses and CIDR ranges.
+func NewURLPolicy(allowed []string) (*URLPolicy, error) {
+	policy := &URLPolicy{allowedHosts: make(map[string]bool)}
+	for _, entry := range allowed {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			policy.allowedPrefixes = append(policy.allowedPrefixes, prefix.Masked())
+			continue
+		}
+		if addr, err := netip.ParseAddr(entry); err == nil {
+			policy.allowedPrefixes = append(policy.allowedPrefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		if strings.ContainsAny(entry, "/:") {
+			return nil, fmt.Errorf("invalid allowed host %q", entry)
+		}
+		policy.allowedHosts[strings.TrimSuffix(entry, ".")] = true
+	}
+	return policy, nil
+}
+
+// CheckURL validates the scheme and host of rawURL and, unless the host is
+// allowed, that it only resolves to public addresses. Passing the check does
+// not guarantee the URL will be fetchable: the addresses are checked again
+// when connecting, as DNS answers may change in between.
+func (policy *URLPolicy) CheckURL(ctx context.Context, rawURL string) error {
+	parsedUrl, err := policy.checkScheme(rawURL)
+	if err != nil {
+		return err
+	}
+
+	host := parsedUrl.Hostname()
+	if policy.hostAllowed(host) {
+		return nil
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if reason := policy.checkAddr(addr); reason != "" {
+			return &URLNotAllowedError{URL: rawURL, Reason: fmt.Sprintf("%s is %s", host, reason)}
+		}
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %v", host, err)
+	}
+	for _, addr := range addrs {
+		if reason := policy.checkAddr(addr); reason != "" {
+			return &URLNotAllowedError{URL: rawURL, Reason: fmt.Sprintf("%s resolves to %s, %s", host, addr.Unmap(), reason)}
+		}
+	}
+	return nil
+}
+
+// checkScheme validates the parts of rawURL that do not need DNS.
+func (policy *URLPolicy) checkScheme(rawURL string) (*url.URL, error) {
+	parsedUrl, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, &URLNotAllowedError{URL: rawURL, Reason: "invalid URL"}
+	}
+	if parsedUrl.Scheme != "http" && parsedUrl.Scheme != "https" {
+		return nil, &URLNotAllowedError{URL: rawURL, Reason: "only http and https URLs can be fetched"}
+	}
+	if parsedUrl.Hostname() == "" {
+		return nil, &URLNotAllowedError{URL: rawURL, Reason: "missing host"}
+	}
+	return parsedUrl, nil
+}
+
+func (policy *URLPolicy) hostAllowed(host string) bool {
+	return policy.allowedHosts[strings.TrimSuffix(strings.ToLower(host), ".")]
+}
+
+// checkAddr returns why addr may not be connected to, or "" if it may.
+func (policy *URLPolicy) checkAddr(addr netip.Addr) string {
+	addr = addr.Unmap()
+	for _, prefix := range policy.allowedPrefixes {
+		if prefix.Contains(addr) {
+			return ""
+		}
+	}
+
+	switch {
+	case addr.IsLoopback():
+		return "a loopback address"
+	case addr.IsPrivate():
+		return "a private address"
+	case addr.IsLinkLocalUnicast(), addr.IsLinkLocalMulticast():
+		return "a link-local address"
+	case addr.IsUnspecified():
+		return "an unspecified address"
+	case addr.IsMulticast(), addr.IsInterfaceLocalMulticast():
+		return "a multicast address"
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return "a reserved address"
+		}
+	}
+	return ""
+}
+
+// proxy returns the Proxy function of the transport for the proxies of
+// config. A proxy connects to the host of the request itself, so the URL is
+// checked against the policy, resolving its host, before it is handed over.
+// Unlike direct connections, proxied ones are not protected against DNS
+// rebinding, as the proxy resolves the host again on its own.
+//
+// Connections to proxyAddrs skip the address checks, see dialContext, so
+// requests that would go straight to one of them, such as those NO_PROXY or
+// a local host exempt from the proxy, are refused.
+func (policy *URLPolicy) proxy(config *httpproxy.Config, proxyAddrs map[string]bool) func(req *http.Request) (*url.URL, error) {
+	proxyFor := config.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		proxyURL, err := proxyFor(req.URL)
+		if err != nil {
+			return nil, err
+		}
+		if proxyURL == nil {
+			if proxyAddrs[dialAddr(req.URL)] && !policy.hostAllowed(req.URL.Hostname()) {
+				return nil, &URLNotAllowedError{URL: req.URL.String(), Reason: "it is the address of a proxy"}
+			}
+			return nil, nil
+		}
+		if err := policy.CheckURL(req.Context(), req.URL.String()); err != nil {
+			return nil, err
+		}
+		return proxyURL, nil
+	}
+}
+
+// proxyAddrs returns the addresses of the proxies of config, which are set by
+// the operator and may be connected to whatever they resolve to.
+func proxyAddrs(config *httpproxy.Config) map[string]bool {
+	addrs := make(map[string]bool)
+	for _, rawURL := range []string{config.HTTPProxy, config.HTTPSProxy} {
+		if rawURL == "" {
+			continue
+		}
+		// Like net/http, accept proxies given without a scheme.
+		if !strings.Contains(rawURL, "://") {
+			rawURL = "http://" + rawURL
+		}
+		if proxyURL, err := url.Parse(rawURL); err == nil && proxyURL.Hostname() != "" {
+			addrs[dialAddr(proxyURL)] = true
+		}
+	}
+	return addrs
+}
+
+// dialAddr returns the address net/http connects to for u, with the default
+// port of its scheme if it has none, and its host in lower case.
+func dialAddr(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		switch u.Scheme {
+		case "https":
+			port = "443"
+		case "socks5", "socks5h":
+			port = "1080"
+		default:
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(strings.ToLower(u.Hostname()), port)
+}
+
+// dialContext connects like dialer but refuses addresses the policy blocks,
+// except for trustedAddrs, the host:port addresses of the proxies.
+// The check runs on the address actually connected to, after name resolution,
+// so a host that resolves to a public address when checked and to an
+// internal one when fetched (DNS rebinding) is still refused.
+func (policy *URLPolicy) dialContext(dialer *net.Dialer, trustedAddrs map[string]bool) func(ctx context.Context, network, address string) (net.Conn, error) {
+	checkedDialer := *dialer
+	checkedDialer.Control = func(network, address string, conn syscall.RawConn) error {
+		addrPort, err := netip.ParseAddrPort(address)
+		if err != nil {
+			return err
+		}
+		if reason := policy.checkAddr(addrPort.Addr()); reason != "" {
+			return &URLNotAllowedError{URL: address, Reason: reason}
+		}
+		return nil
+	}
+
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		if policy.hostAllowed(host) || trustedAddrs[strings.ToLower(address)] {
+			return dialer.DialContext(ctx, network, address)
+		}
+		return checkedDialer.DialContext(ctx, network, address)
+	}
+}
@@ -0,0 +1,124 @@
+package feedfetcher
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCheckURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		allowed []string
+		wantErr bool
+	}{
+		{name: "public address", url: "http://93.184.216.34/feed"},
+		{name: "https", url: "https://93.184.216.34/feed"},
+		{name: "other scheme", url: "ftp://93.184.216.34/feed", wantErr: true},
+		{name: "file", url: "file:///etc/passwd", wantErr: true},
+		{name: "missing host", url: "http:///feed", wantErr: true},
+		{name: "loopback", url: "http://127.0.0.1/feed", wantErr: true},
+		{name: "IPv6 loopback", url: "http://[::1]/feed", wantErr: true},
+		{name: "IPv4-mapped loopback", url: "http://[::ffff:127.0.0.1]/feed", wantErr: true},
+		{name: "private", url: "http://10.1.2.3/feed", wantErr: true},
+		{name: "link-local", url: "http://169.254.169.254/latest/meta-data", wantErr: true},
+		{name: "unspecified", url: "http://0.0.0.0/feed", wantErr: true},
+		{name: "carrier-grade NAT", url: "http://100.64.0.1/feed", wantErr: true},
+		{name: "NAT64", url: "http://[64:ff9b::a00:1]/feed", wantErr: true},
+		{name: "allowed host", url: "http://localhost:8080/feed", allowed: []string{"localhost"}},
+		{name: "allowed address", url: "http://127.0.0.1/feed", allowed: []string{"127.0.0.1"}},
+		{name: "allowed range", url: "http://10.1.2.3/feed", allowed: []string{"10.0.0.0/8"}},
+		{name: "outside the allowed range", url: "http://192.168.1.1/feed", allowed: []string{"10.0.0.0/8"}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policy, err := NewURLPolicy(test.allowed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = policy.CheckURL(context.Background(), test.url)
+			var notAllowed *URLNotAllowedError
+			switch {
+			case test.wantErr && !errors.As(err, &notAllowed):
+				t.Errorf("CheckURL(%q) = %v, want a *URLNotAllowedError", test.url, err)
+			case !test.wantErr && err != nil:
+				t.Errorf("CheckURL(%q) = %v, want nil", test.url, err)
+			}
+		})
+	}
+}
+
+func TestNewURLPolicyInvalidHost(t *testing.T) {
+	if _, err := NewURLPolicy([]string{"example.com/feeds"}); err == nil {
+		t.Error("NewURLPolicy accepted a host with a path")
+	}
+}
+
+// TestProxy checks that the proxy, which runs on loopback like many do, is
+// reachable while other local services stay out of reach, including
+// through redirects and the local hosts the proxy is bypassed for.
+func TestProxy(t *testing.T) {
+	var serviceHits atomic.Int32
+	service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serviceHits.Add(1)
+	}))
+	defer service.Close()
+	serviceURL, _ := url.Parse(service.URL)
+	serviceOnLocalhost := "http://localhost:" + serviceURL.Port() + "/feed"
+
+	var proxied atomic.Int32
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied.Add(1)
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, serviceOnLocalhost, http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(testFeed))
+	}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	for _, name := range []string{"HTTPS_PROXY", "https_proxy", "NO_PROXY", "no_proxy", "http_proxy", "REQUEST_METHOD"} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("HTTP_PROXY", "http://localhost:"+proxyURL.Port())
+
+	fetcher, err := NewFetcher(Options{MaxRetries: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := fetcher.FetchFeed(context.Background(), "http://93.184.216.34/feed")
+	if err != nil {
+		t.Fatalf("fetching through the proxy failed: %v", err)
+	}
+	if result.Feed.Channel.Title != "Test feed" || proxied.Load() != 1 {
+		t.Errorf("feed %q was not fetched through the proxy", result.Feed.Channel.Title)
+	}
+
+	for _, feedURL := range []string{
+		// Not proxied, as the host is local, so connected to directly.
+		serviceOnLocalhost,
+		// The proxy itself, requested directly.
+		"http://localhost:" + proxyURL.Port() + "/feed",
+		proxy.URL + "/feed",
+		// Redirected by the proxied answer to the local service.
+		"http://93.184.216.34/redirect",
+	} {
+		_, err := fetcher.FetchFeed(context.Background(), feedURL)
+		var notAllowed *URLNotAllowedError
+		if !errors.As(err, &notAllowed) {
+			t.Errorf("FetchFeed(%q) = %v, want a *URLNotAllowedError", feedURL, err)
+		}
+	}
+	if serviceHits.Load() != 0 {
+		t.Errorf("the local service was requested %d time(s)", serviceHits.Load())
+	}
+}
@@ -0,0 +1,204 @@
+package feedfetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	DefaultHostInterval    = 2 * time.Second
+	DefaultHostBurst       = 3
+	DefaultHostConcurrency = 2
+	DefaultMaxRetryAfter   = 24 * time.Hour
+
+	// hostSweepInterval is how often hosts that are no longer fetched are
+	// forgotten.
+	hostSweepInterval = 10 * time.Minute
+)
+
+// HostBackoffError is returned for fetches from a host that recently asked,
+// through Retry-After, not to be requested again before Until.
+type HostBackoffError struct {
+	Host  string
+	Until time.Time
+}
+
+func (err *HostBackoffError) Error() string {
+	return fmt.Sprintf("%s asked not to be fetched before %s", err.Host, err.Until.Format(time.RFC1123))
+}
+
+// RetryAt returns when a fetch that failed with err may be tried again, if
+// the server said so through Retry-After.
+func RetryAt(err error) (time.Time, bool) {
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) && !statusErr.RetryAt.IsZero() {
+		return statusErr.RetryAt, true
+	}
+	var backoffErr *HostBackoffError
+	if errors.As(err, &backoffErr) {
+		return backoffErr.Until, true
+	}
+	return time.Time{}, false
+}
+
+// hostLimiter keeps fetches polite towards each host: a token bucket spaces
+// requests out, a semaphore caps how many run at once, and a host that
+// answered with Retry-After is left alone until the time it gave.
+type hostLimiter struct {
+	interval    time.Duration
+	burst       int
+	concurrency int
+
+	mu        sync.Mutex
+	hosts     map[string]*hostState
+	lastSweep time.Time
+}
+
+type hostState struct {
+	limiter *rate.Limiter
+	slots   chan struct{}
+	// backoffUntil and users are guarded by the mutex of the hostLimiter.
+	backoffUntil time.Time
+	// users counts the fetches waiting for or holding a slot, which keep
+	// the state from being forgotten.
+	users int
+}
+
+func newHostLimiter(interval time.Duration, burst int, concurrency int) *hostLimiter {
+	return &hostLimiter{
+		interval:    interval,
+		burst:       burst,
+		concurrency: concurrency,
+		hosts:       make(map[string]*hostState),
+	}
+}
+
+// host returns the state of host, creating it if needed. The mutex of the
+// limiter must be held.
+func (limiter *hostLimiter) host(host string) *hostState {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+
+	limiter.evictIdle(time.Now())
+	state, ok := limiter.hosts[host]
+	if !ok {
+		state = &hostState{
+			limiter: rate.NewLimiter(rate.Every(limiter.interval), limiter.burst),
+			slots:   make(chan struct{}, limiter.concurrency),
+		}
+		limiter.hosts[host] = state
+	}
+	return state
+}
+
+// evictIdle forgets, at most once per hostSweepInterval, the hosts that no
+// fetch uses, whose token bucket is full again and whose backoff is over.
+// Their state is the same as a new one's, and a long-running aggregator
+// would otherwise keep one for every host it ever fetched from.
+func (limiter *hostLimiter) evictIdle(now time.Time) {
+	if now.Sub(limiter.lastSweep) < hostSweepInterval {
+		return
+	}
+	limiter.lastSweep = now
+
+	for host, state := range limiter.hosts {
+		if state.users == 0 && !now.Before(state.backoffUntil) && state.limiter.TokensAt(now) >= float64(limiter.burst) {
+			delete(limiter.hosts, host)
+		}
+	}
+}
+
+// acquire waits until a request to host may start and returns the function
+// that ends it. It fails with a *HostBackoffError while the host is backed
+// off, rather than waiting for what may be hours.
+func (limiter *hostLimiter) acquire(ctx context.Context, host string) (func(), error) {
+	limiter.mu.Lock()
+	state := limiter.host(host)
+	state.users++
+	backoffUntil := state.backoffUntil
+	limiter.mu.Unlock()
+	done := func() {
+		limiter.mu.Lock()
+		state.users--
+		limiter.mu.Unlock()
+	}
+
+	if time.Now().Before(backoffUntil) {
+		done()
+		return nil, &HostBackoffError{Host: host, Until: backoffUntil}
+	}
+
+	select {
+	case state.slots <- struct{}{}:
+	case <-ctx.Done():
+		done()
+		return nil, ctx.Err()
+	}
+	release := func() {
+		<-state.slots
+		done()
+	}
+
+	// The host may have asked to be left alone while we waited for a slot.
+	limiter.mu.Lock()
+	backoffUntil = state.backoffUntil
+	limiter.mu.Unlock()
+	if time.Now().Before(backoffUntil) {
+		release()
+		return nil, &HostBackoffError{Host: host, Until: backoffUntil}
+	}
+
+	if err := state.limiter.Wait(ctx); err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
+
+// backOff stops requests to host until the given time.
+func (limiter *hostLimiter) backOff(host string, until time.Time) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	state := limiter.host(host)
+	if until.After(state.backoffUntil) {
+		state.backoffUntil = until
+	}
+}
+
+// parseRetryAfter reads a Retry-After header, given either in seconds or as
+// an HTTP date, and returns when to retry, at most maxWait from now.
+func parseRetryAfter(value string, now time.Time, maxWait time.Duration) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+
+	var retryAt time.Time
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if seconds < 0 {
+			return time.Time{}, false
+		}
+		// Huge values would overflow a Duration; they are capped below anyway.
+		seconds = min(seconds, int64(maxWait/time.Second)+1)
+		retryAt = now.Add(time.Duration(seconds) * time.Second)
+	} else if date, err := http.ParseTime(value); err == nil {
+		retryAt = date.Local()
+	} else {
+		return time.Time{}, false
+	}
+
+	if !retryAt.After(now) {
+		return time.Time{}, false
+	}
+	if latest := now.Add(maxWait); retryAt.After(latest) {
+		retryAt = latest
+	}
+	return retryAt, true
+}
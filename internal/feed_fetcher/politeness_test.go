@@ -0,0 +1,204 @@
+package feedfetcher
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	maxWait := time.Hour
+
+	tests := []struct {
+		name   string
+		value  string
+		want   time.Time
+		wantOK bool
+	}{
+		{name: "seconds", value: "120", want: now.Add(2 * time.Minute), wantOK: true},
+		{name: "seconds with spaces", value: " 30 ", want: now.Add(30 * time.Second), wantOK: true},
+		{name: "HTTP date", value: "Thu, 02 Jan 2025 03:34:05 GMT", want: now.Add(30 * time.Minute), wantOK: true},
+		{name: "obsolete HTTP date", value: "Thursday, 02-Jan-25 03:34:05 GMT", want: now.Add(30 * time.Minute), wantOK: true},
+		{name: "seconds over the cap", value: "7200", want: now.Add(maxWait), wantOK: true},
+		{name: "seconds overflowing a duration", value: "99999999999999999", want: now.Add(maxWait), wantOK: true},
+		{name: "HTTP date over the cap", value: "Fri, 03 Jan 2025 03:04:05 GMT", want: now.Add(maxWait), wantOK: true},
+		{name: "zero", value: "0"},
+		{name: "negative", value: "-10"},
+		{name: "past HTTP date", value: "Wed, 01 Jan 2025 00:00:00 GMT"},
+		{name: "now", value: "Thu, 02 Jan 2025 03:04:05 GMT"},
+		{name: "empty", value: ""},
+		{name: "garbage", value: "soon"},
+		{name: "fraction", value: "1.5"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := parseRetryAfter(test.value, now, maxWait)
+			if ok != test.wantOK {
+				t.Fatalf("parseRetryAfter(%q) ok = %v, want %v", test.value, ok, test.wantOK)
+			}
+			if ok && !got.Equal(test.want) {
+				t.Errorf("parseRetryAfter(%q) = %v, want %v", test.value, got, test.want)
+			}
+		})
+	}
+}
+
+func TestHostLimiterRate(t *testing.T) {
+	limiter := newHostLimiter(100*time.Millisecond, 2, 10)
+	ctx := context.Background()
+
+	start := time.Now()
+	for range 3 {
+		release, err := limiter.acquire(ctx, "example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		release()
+	}
+	// The burst lets two requests through at once, the third waits.
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("three requests took %v, want the third to wait for a token", elapsed)
+	}
+
+	// Other hosts have buckets of their own.
+	start = time.Now()
+	release, err := limiter.acquire(ctx, "example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("a request to another host waited %v", elapsed)
+	}
+}
+
+func TestHostLimiterConcurrency(t *testing.T) {
+	limiter := newHostLimiter(time.Millisecond, 10, 1)
+
+	release, err := limiter.acquire(context.Background(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	// Host names are compared without case or trailing dot.
+	if _, err := limiter.acquire(ctx, "EXAMPLE.com."); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("second concurrent request = %v, want it to wait for the first", err)
+	}
+
+	release()
+	release, err = limiter.acquire(context.Background(), "example.com")
+	if err != nil {
+		t.Fatalf("request after the first ended failed: %v", err)
+	}
+	release()
+}
+
+func TestHostLimiterBackoff(t *testing.T) {
+	limiter := newHostLimiter(time.Millisecond, 10, 10)
+	until := time.Now().Add(time.Hour)
+	limiter.backOff("example.com", until)
+	// An earlier time does not shorten the backoff.
+	limiter.backOff("example.com", time.Now().Add(time.Minute))
+
+	_, err := limiter.acquire(context.Background(), "example.com")
+	var backoffErr *HostBackoffError
+	if !errors.As(err, &backoffErr) {
+		t.Fatalf("acquire() = %v, want a *HostBackoffError", err)
+	}
+	if !backoffErr.Until.Equal(until) {
+		t.Errorf("backoff until %v, want %v", backoffErr.Until, until)
+	}
+	if retryAt, ok := RetryAt(err); !ok || !retryAt.Equal(until) {
+		t.Errorf("RetryAt() = %v, %v, want %v", retryAt, ok, until)
+	}
+
+	release, err := limiter.acquire(context.Background(), "example.org")
+	if err != nil {
+		t.Fatalf("another host is backed off too: %v", err)
+	}
+	release()
+
+	limiter.backOff("example.net", time.Now().Add(-time.Second))
+	release, err = limiter.acquire(context.Background(), "example.net")
+	if err != nil {
+		t.Fatalf("a backoff in the past still applies: %v", err)
+	}
+	release()
+}
+
+func TestHostLimiterEviction(t *testing.T) {
+	limiter := newHostLimiter(time.Second, 1, 1)
+
+	release, err := limiter.acquire(context.Background(), "idle.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	busyRelease, err := limiter.acquire(context.Background(), "busy.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busyRelease()
+	limiter.backOff("backed-off.example", time.Now().Add(24*time.Hour))
+
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	// Too early for a sweep, and the bucket of idle.example is not full.
+	limiter.evictIdle(time.Now())
+	if len(limiter.hosts) != 3 {
+		t.Fatalf("%d hosts before a sweep, want 3", len(limiter.hosts))
+	}
+
+	limiter.evictIdle(time.Now().Add(hostSweepInterval + time.Minute))
+	if _, ok := limiter.hosts["idle.example"]; ok {
+		t.Error("idle host was kept")
+	}
+	if _, ok := limiter.hosts["busy.example"]; !ok {
+		t.Error("host with a request in progress was forgotten")
+	}
+	if _, ok := limiter.hosts["backed-off.example"]; !ok {
+		t.Error("backed off host was forgotten")
+	}
+}
+
+func TestFetchFeedRetryAfter(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	fetcher, err := NewFetcher(Options{AllowedHosts: []string{"127.0.0.1"}, MaxRetries: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = fetcher.FetchFeed(context.Background(), server.URL)
+	var statusErr *HTTPStatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("FetchFeed() = %v, want a 503 *HTTPStatusError", err)
+	}
+	retryAt, ok := RetryAt(err)
+	if wait := time.Until(retryAt); !ok || wait < 55*time.Second || wait > 60*time.Second {
+		t.Errorf("RetryAt() = %v, %v, want in a minute", retryAt, ok)
+	}
+
+	_, err = fetcher.FetchFeed(context.Background(), server.URL)
+	var backoffErr *HostBackoffError
+	if !errors.As(err, &backoffErr) {
+		t.Fatalf("FetchFeed() during the backoff = %v, want a *HostBackoffError", err)
+	}
+	if requests != 1 {
+		t.Errorf("server got %d requests, want 1", requests)
+	}
+}
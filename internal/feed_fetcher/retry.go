@@ -0,0 +1,107 @@
+package feedfetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+const (
+	DefaultMaxRetries  = 3
+	DefaultRetryBudget = 2 * time.Minute
+
+	// retryBaseDelay is the wait before the first retry, doubled for each
+	// following one up to retryMaxDelay.
+	retryBaseDelay = 1 * time.Second
+	retryMaxDelay  = 30 * time.Second
+)
+
+// RetriedError is returned when a fetch still failed after being retried.
+type RetriedError struct {
+	Retries int
+	Err     error
+}
+
+func (err *RetriedError) Error() string {
+	return fmt.Sprintf("%v (retried %d time(s))", err.Err, err.Retries)
+}
+
+func (err *RetriedError) Unwrap() error {
+	return err.Err
+}
+
+// Transient reports whether err is a failure that may well not happen again
+// if the fetch is retried shortly: network errors, timeouts, truncated
+// responses and 5xx statuses that signal an overloaded or restarting server.
+// A server that gave a Retry-After time is not retried before then, and
+// anything else, such as a 404, a refused URL or a malformed feed, would fail
+// the same way.
+func Transient(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		if !statusErr.RetryAt.IsZero() {
+			return false
+		}
+		switch statusErr.StatusCode {
+		case http.StatusRequestTimeout, http.StatusTooEarly, http.StatusTooManyRequests,
+			http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+
+	var notAllowedErr *URLNotAllowedError
+	var backoffErr *HostBackoffError
+	var limitErr *LimitError
+	if errors.As(err, &notAllowedErr) || errors.As(err, &backoffErr) || errors.As(err, &limitErr) || errors.Is(err, ErrBodyTooLarge) {
+		return false
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return !dnsErr.IsNotFound
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF)
+}
+
+// retryDelay returns how long to wait before the given retry, counting from
+// 1: an exponentially growing delay of which a random half is taken off, so
+// that feeds failing together do not retry together.
+func retryDelay(retry int) time.Duration {
+	delay := retryMaxDelay
+	if shift := retry - 1; shift < 8 {
+		delay = min(retryBaseDelay<<shift, retryMaxDelay)
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// sleep waits for delay, or until ctx is done.
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,186 @@
+package feedfetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestTransient(t *testing.T) {
+	opError := func(err error) error {
+		return &url.Error{Op: "Get", URL: "http://example.com/feed", Err: &net.OpError{Op: "dial", Net: "tcp", Err: err}}
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "408", err: &HTTPStatusError{StatusCode: http.StatusRequestTimeout}, want: true},
+		{name: "425", err: &HTTPStatusError{StatusCode: http.StatusTooEarly}, want: true},
+		{name: "429", err: &HTTPStatusError{StatusCode: http.StatusTooManyRequests}, want: true},
+		{name: "500", err: &HTTPStatusError{StatusCode: http.StatusInternalServerError}, want: true},
+		{name: "502", err: &HTTPStatusError{StatusCode: http.StatusBadGateway}, want: true},
+		{name: "503", err: &HTTPStatusError{StatusCode: http.StatusServiceUnavailable}, want: true},
+		{name: "504", err: &HTTPStatusError{StatusCode: http.StatusGatewayTimeout}, want: true},
+		{name: "wrapped 503", err: fmt.Errorf("fetching: %w", &HTTPStatusError{StatusCode: http.StatusServiceUnavailable}), want: true},
+		{name: "503 with Retry-After", err: &HTTPStatusError{StatusCode: http.StatusServiceUnavailable, RetryAt: time.Now().Add(time.Minute)}},
+		{name: "429 with Retry-After", err: &HTTPStatusError{StatusCode: http.StatusTooManyRequests, RetryAt: time.Now().Add(time.Minute)}},
+		{name: "400", err: &HTTPStatusError{StatusCode: http.StatusBadRequest}},
+		{name: "403", err: &HTTPStatusError{StatusCode: http.StatusForbidden}},
+		{name: "404", err: &HTTPStatusError{StatusCode: http.StatusNotFound}},
+		{name: "410", err: &HTTPStatusError{StatusCode: http.StatusGone}},
+		{name: "501", err: &HTTPStatusError{StatusCode: http.StatusNotImplemented}},
+		{name: "connection refused", err: opError(syscall.ECONNREFUSED), want: true},
+		{name: "connection reset", err: opError(syscall.ECONNRESET), want: true},
+		{name: "connection aborted", err: opError(syscall.ECONNABORTED), want: true},
+		{name: "broken pipe", err: opError(syscall.EPIPE), want: true},
+		{name: "dial timeout", err: opError(os.ErrDeadlineExceeded), want: true},
+		{name: "deadline exceeded", err: context.DeadlineExceeded, want: true},
+		{name: "DNS timeout", err: opError(&net.DNSError{Err: "i/o timeout", Name: "example.com", IsTimeout: true}), want: true},
+		{name: "DNS server failure", err: opError(&net.DNSError{Err: "server misbehaving", Name: "example.com", IsTemporary: true}), want: true},
+		{name: "unknown host", err: opError(&net.DNSError{Err: "no such host", Name: "example.com", IsNotFound: true})},
+		{name: "truncated body", err: fmt.Errorf("reading body: %w", io.ErrUnexpectedEOF), want: true},
+		{name: "connection closed", err: &url.Error{Op: "Get", URL: "http://example.com/feed", Err: io.EOF}, want: true},
+		{name: "canceled", err: context.Canceled},
+		{name: "wrapped cancel", err: &url.Error{Op: "Get", URL: "http://example.com/feed", Err: context.Canceled}},
+		{name: "URL not allowed", err: &URLNotAllowedError{URL: "http://127.0.0.1/", Reason: "loopback"}},
+		{name: "host backoff", err: &HostBackoffError{Host: "example.com", Until: time.Now().Add(time.Minute)}},
+		{name: "limit", err: &LimitError{Limit: "items", Max: 5}},
+		{name: "body too large", err: ErrBodyTooLarge},
+		{name: "other", err: errors.New("XML syntax error")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Transient(test.err); got != test.want {
+				t.Errorf("Transient(%v) = %v, want %v", test.err, got, test.want)
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		retry int
+		max   time.Duration
+	}{
+		{retry: 1, max: time.Second},
+		{retry: 2, max: 2 * time.Second},
+		{retry: 3, max: 4 * time.Second},
+		{retry: 5, max: 16 * time.Second},
+		{retry: 6, max: retryMaxDelay},
+		{retry: 9, max: retryMaxDelay},
+		{retry: 64, max: retryMaxDelay},
+		{retry: 1000, max: retryMaxDelay},
+	}
+
+	for _, test := range tests {
+		for range 100 {
+			// A random half of the delay is taken off.
+			if delay := retryDelay(test.retry); delay < test.max/2 || delay > test.max {
+				t.Fatalf("retryDelay(%d) = %v, want between %v and %v", test.retry, delay, test.max/2, test.max)
+			}
+		}
+	}
+}
+
+// flakyServer fails the first failures requests with a 503 and then serves
+// testFeed. It counts the requests made in requests.
+func flakyServer(t *testing.T, failures int32, requests *atomic.Int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/rss+xml")
+		io.WriteString(w, testFeed)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFetchFeedRetries(t *testing.T) {
+	var requests atomic.Int32
+	server := flakyServer(t, 2, &requests)
+
+	fetcher, err := NewFetcher(Options{AllowedHosts: []string{"127.0.0.1"}, HostInterval: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := fetcher.FetchFeed(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("FetchFeed() failed: %v", err)
+	}
+	if result.Retries != 2 {
+		t.Errorf("Retries = %d, want 2", result.Retries)
+	}
+	if got := requests.Load(); got != 3 {
+		t.Errorf("server got %d requests, want 3", got)
+	}
+}
+
+func TestFetchFeedRetriesExhausted(t *testing.T) {
+	var requests atomic.Int32
+	server := flakyServer(t, 2, &requests)
+
+	fetcher, err := NewFetcher(Options{AllowedHosts: []string{"127.0.0.1"}, HostInterval: time.Millisecond, MaxRetries: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = fetcher.FetchFeed(context.Background(), server.URL)
+	var retriedErr *RetriedError
+	if !errors.As(err, &retriedErr) {
+		t.Fatalf("FetchFeed() = %v, want a *RetriedError", err)
+	}
+	if retriedErr.Retries != 1 {
+		t.Errorf("Retries = %d, want 1", retriedErr.Retries)
+	}
+	var statusErr *HTTPStatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("FetchFeed() = %v, want it to wrap the 503", err)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("server got %d requests, want 2", got)
+	}
+}
+
+func TestFetchFeedPermanentError(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	fetcher, err := NewFetcher(Options{AllowedHosts: []string{"127.0.0.1"}, HostInterval: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = fetcher.FetchFeed(context.Background(), server.URL)
+	var statusErr *HTTPStatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("FetchFeed() = %v, want a 404 *HTTPStatusError", err)
+	}
+	var retriedErr *RetriedError
+	if errors.As(err, &retriedErr) {
+		t.Errorf("FetchFeed() = %v, want a 404 not to be retried", err)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("server got %d requests, want 1", got)
+	}
+}
@@ -0,0 +1,58 @@
+package feedfetcher
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/alancorleto/gator/internal/feed_fetcher")
+
+// clientTrace records the steps of an HTTP request as events of span: name
+// resolution, connection, TLS handshake and the wait for the response.
+func clientTrace(span trace.Span) *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(info httptrace.DNSStartInfo) {
+			span.AddEvent("dns.start", trace.WithAttributes(attribute.String("host", info.Host)))
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			attrs := []attribute.KeyValue{attribute.Int("addresses", len(info.Addrs))}
+			if info.Err != nil {
+				attrs = append(attrs, attribute.String("error", info.Err.Error()))
+			}
+			span.AddEvent("dns.done", trace.WithAttributes(attrs...))
+		},
+		ConnectStart: func(network, addr string) {
+			span.AddEvent("connect.start", trace.WithAttributes(attribute.String("address", addr)))
+		},
+		ConnectDone: func(network, addr string, err error) {
+			attrs := []attribute.KeyValue{attribute.String("address", addr)}
+			if err != nil {
+				attrs = append(attrs, attribute.String("error", err.Error()))
+			}
+			span.AddEvent("connect.done", trace.WithAttributes(attrs...))
+		},
+		TLSHandshakeStart: func() {
+			span.AddEvent("tls.start")
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			attrs := []attribute.KeyValue{}
+			if err != nil {
+				attrs = append(attrs, attribute.String("error", err.Error()))
+			}
+			span.AddEvent("tls.done", trace.WithAttributes(attrs...))
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			span.AddEvent("connection", trace.WithAttributes(attribute.Bool("reused", info.Reused)))
+		},
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			span.AddEvent("request.sent")
+		},
+		GotFirstResponseByte: func() {
+			span.AddEvent("response.first_byte")
+		},
+	}
+}
@@ -0,0 +1,140 @@
+package feedfetcher
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+const testFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0"><channel>
+<title>Test feed</title>
+<item><title>First</title><link>http://example.com/1</link></item>
+<item><title>Second</title><link>http://example.com/2</link></item>
+</channel></rss>`
+
+// exporter records the spans of the package tracer, which delegates to the
+// first global tracer provider set only.
+var exporter = sync.OnceValue(func() *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	return exporter
+})
+
+// traceFetch fetches path from a server answering with handler and returns
+// the spans recorded meanwhile, by name.
+func traceFetch(t *testing.T, handler http.HandlerFunc, path string) (map[string]tracetest.SpanStub, error) {
+	t.Helper()
+	exporter().Reset()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	fetcher, err := NewFetcher(Options{AllowedHosts: []string{"127.0.0.1"}, MaxRetries: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, fetchErr := fetcher.FetchFeed(context.Background(), server.URL+path)
+
+	spans := make(map[string]tracetest.SpanStub)
+	for _, span := range exporter().GetSpans() {
+		if _, ok := spans[span.Name]; ok {
+			t.Fatalf("span %q recorded twice", span.Name)
+		}
+		spans[span.Name] = span
+	}
+	return spans, fetchErr
+}
+
+func spanAttr(span tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, attr := range span.Attributes {
+		if attr.Key == key {
+			return attr.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestFetchFeedSpans(t *testing.T) {
+	spans, err := traceFetch(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(testFeed))
+	}, "/feed")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root, ok := spans["FetchFeed"]
+	if !ok {
+		t.Fatalf("no FetchFeed span in %v", spans)
+	}
+	if root.Parent.IsValid() {
+		t.Error("FetchFeed span has a parent")
+	}
+	for _, name := range []string{"wait for host", "GET", "parse feed"} {
+		span, ok := spans[name]
+		if !ok {
+			t.Fatalf("no %q span", name)
+		}
+		if span.Parent.SpanID() != root.SpanContext.SpanID() {
+			t.Errorf("%q span is not a child of FetchFeed", name)
+		}
+		if span.Status.Code == codes.Error {
+			t.Errorf("%q span has error status: %s", name, span.Status.Description)
+		}
+	}
+	if len(spans) != 4 {
+		t.Errorf("got %d spans, want 4", len(spans))
+	}
+
+	if got := spanAttr(root, "gator.fetch.outcome").AsString(); got != "success" {
+		t.Errorf("gator.fetch.outcome = %q, want success", got)
+	}
+	get := spans["GET"]
+	if got := spanAttr(get, "http.response.status_code").AsInt64(); got != http.StatusOK {
+		t.Errorf("http.response.status_code = %d, want %d", got, http.StatusOK)
+	}
+	if got := spanAttr(get, "http.response.body.size").AsInt64(); got != int64(len(testFeed)) {
+		t.Errorf("http.response.body.size = %d, want %d", got, len(testFeed))
+	}
+	if got := spanAttr(spans["parse feed"], "gator.feed.items").AsInt64(); got != 2 {
+		t.Errorf("gator.feed.items = %d, want 2", got)
+	}
+	if got := spanAttr(spans["wait for host"], "server.address").AsString(); got != "127.0.0.1" {
+		t.Errorf("server.address = %q, want 127.0.0.1", got)
+	}
+}
+
+func TestFetchFeedSpansOnError(t *testing.T) {
+	spans, err := traceFetch(t, http.NotFound, "/missing")
+	if err == nil {
+		t.Fatal("fetching a missing feed succeeded")
+	}
+
+	if _, ok := spans["parse feed"]; ok {
+		t.Error("a feed that was not downloaded was parsed")
+	}
+	for _, name := range []string{"FetchFeed", "GET"} {
+		span, ok := spans[name]
+		if !ok {
+			t.Fatalf("no %q span", name)
+		}
+		if span.Status.Code != codes.Error {
+			t.Errorf("%q span status = %v, want error", name, span.Status.Code)
+		}
+	}
+	if got := spanAttr(spans["GET"], "http.response.status_code").AsInt64(); got != http.StatusNotFound {
+		t.Errorf("http.response.status_code = %d, want %d", got, http.StatusNotFound)
+	}
+	if got := spanAttr(spans["FetchFeed"], "gator.fetch.outcome").AsString(); got != "http_error" {
+		t.Errorf("gator.fetch.outcome = %q, want http_error", got)
+	}
+}
@@ -3,52 +3,385 @@ package feedscraper
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
 	"strings"
 	"time"
 
 	database "github.com/alancorleto/gator/internal/database"
 	feedfetcher "github.com/alancorleto/gator/internal/feed_fetcher"
+	htmlsanitizer "github.com/alancorleto/gator/internal/html_sanitizer"
+	metrics "github.com/alancorleto/gator/internal/metrics"
+	tracing "github.com/alancorleto/gator/internal/tracing"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func ScrapeNextFeed(db *database.Queries) (string, error) {
-	nextFeed, err := db.GetNextFeedToFetch(context.Background())
+var tracer = otel.Tracer("github.com/alancorleto/gator/internal/feed_scraper")
+
+// ScrapeResult describes a scraped feed.
+type ScrapeResult struct {
+	// FeedName is the title the feed gives itself.
+	FeedName string
+	// URLChange records the move of the feed to a new URL after a permanent
+	// redirect, or is nil if the feed did not move.
+	URLChange *database.FeedUrlChange
+	// Warnings describes problems in the feed that did not prevent parsing it.
+	Warnings []string
+	// NextFetchAt is when the feed is due again after its server asked to be
+	// retried later, or nil.
+	NextFetchAt *time.Time
+	// Retries is how many times fetching the feed was retried after a
+	// transient failure.
+	Retries int
+	// NewPosts is how many posts were stored that were not stored before.
+	NewPosts int
+}
+
+// ErrNoFeedDue is returned by ScrapeNextFeed when every feed is held off
+// until a later time, or there are no feeds at all.
+var ErrNoFeedDue = errors.New("no feed is due for fetching")
+
+// ScrapeNextFeed scrapes the feed fetched least recently among those that are
+// due. Claiming the feed marks it fetched in the same statement, so that
+// concurrent callers each get a different feed. When fetchedBefore is valid,
+// feeds fetched since then are not due, which lets a caller go through every
+// feed once. It must be a time of the database's clock, see GetDatabaseTime,
+// as the feeds are marked fetched with that clock.
+func ScrapeNextFeed(ctx context.Context, db *database.Queries, fetcher *feedfetcher.Fetcher, logger *slog.Logger, fetchedBefore sql.NullTime) (ScrapeResult, error) {
+	ctx, span := tracer.Start(ctx, "ScrapeNextFeed")
+
+	nextFeed, err := db.ClaimNextFeedToFetch(ctx, fetchedBefore)
+	if errors.Is(err, sql.ErrNoRows) {
+		span.SetAttributes(attribute.Bool("gator.feed.due", false))
+		span.End()
+		return ScrapeResult{}, ErrNoFeedDue
+	}
+	if err != nil {
+		tracing.End(span, err)
+		return ScrapeResult{}, err
+	}
+
+	result, err := ScrapeFeed(ctx, db, fetcher, logger, nextFeed)
+	tracing.End(span, err)
+	return result, err
+}
+
+// ScrapeFeed fetches a feed and stores its new posts. The outcome is recorded
+// on the feed: the error of a failed scrape, or none after a successful one.
+// When the server asked to be retried later, the feed is not due again
+// before then. The outcome is also logged, with the ID and URL of the feed.
+func ScrapeFeed(ctx context.Context, db *database.Queries, fetcher *feedfetcher.Fetcher, logger *slog.Logger, feed database.Feed) (ScrapeResult, error) {
+	ctx, span := tracer.Start(ctx, "ScrapeFeed", trace.WithAttributes(
+		attribute.String("gator.feed.id", feed.ID.String()),
+		semconv.URLFull(feed.Url),
+	))
+	logger.Debug("scraping feed", feedAttrs(feed)...)
+
+	result, err := scrapeFeed(ctx, db, fetcher, &feed)
+	err = recordScrapeResult(ctx, db, feed, &result, err)
+	logScrapeResult(logger, feed, result, err)
+
+	span.SetAttributes(
+		attribute.Int("gator.feed.new_posts", result.NewPosts),
+		attribute.Int("gator.fetch.retries", result.Retries),
+	)
+	tracing.End(span, err)
+	return result, err
+}
+
+// recordScrapeResult stores the outcome of a scrape on the feed and returns
+// the error of the scrape, or of storing its outcome.
+func recordScrapeResult(ctx context.Context, db *database.Queries, feed database.Feed, result *ScrapeResult, err error) error {
+	fetchError := sql.NullString{}
+	nextFetchAt := sql.NullTime{}
+	if err != nil {
+		fetchError = sql.NullString{String: err.Error(), Valid: true}
+		if retryAt, ok := feedfetcher.RetryAt(err); ok {
+			nextFetchAt = sql.NullTime{Time: retryAt, Valid: true}
+			result.NextFetchAt = &retryAt
+		}
+	}
+	recordErr := db.SetFeedFetchResult(
+		ctx,
+		database.SetFeedFetchResultParams{
+			ID:             feed.ID,
+			LastFetchError: fetchError,
+			NextFetchAt:    nextFetchAt,
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if recordErr != nil {
+		return fmt.Errorf("failed to record fetch status of %s: %v", feed.Name, recordErr)
+	}
+	return nil
+}
+
+// logScrapeResult logs the outcome of ScrapeFeed. feed is the feed as it is
+// after the scrape, at its new URL if it moved.
+func logScrapeResult(logger *slog.Logger, feed database.Feed, result ScrapeResult, err error) {
+	logger = logger.With(feedAttrs(feed)...)
+
+	if result.URLChange != nil {
+		logger.Info("feed moved",
+			"old_url", result.URLChange.OldUrl,
+			"status", result.URLChange.StatusCode,
+			"merged", result.URLChange.Merged,
+		)
+	}
+	for _, warning := range result.Warnings {
+		logger.Warn("problem in feed", "warning", warning)
+	}
+
+	if err != nil {
+		attrs := []any{"error", err, "retries", result.Retries}
+		if result.NextFetchAt != nil {
+			attrs = append(attrs, "next_fetch_at", *result.NextFetchAt)
+		}
+		logger.Error("failed to scrape feed", attrs...)
+		return
+	}
+	logger.Info("scraped feed",
+		"feed_name", result.FeedName,
+		"new_posts", result.NewPosts,
+		"retries", result.Retries,
+	)
+}
+
+func feedAttrs(feed database.Feed) []any {
+	return []any{"feed_id", feed.ID, "feed_url", feed.Url}
+}
+
+// scrapeFeed does the work of ScrapeFeed. It updates feed when the feed moves
+// to another URL or is merged into another feed.
+func scrapeFeed(ctx context.Context, db *database.Queries, fetcher *feedfetcher.Fetcher, feed *database.Feed) (ScrapeResult, error) {
+	result := ScrapeResult{FeedName: feed.Name}
+
+	fetchResult, err := fetcher.FetchFeed(ctx, feed.Url)
 	if err != nil {
-		return "", err
+		var retriedErr *feedfetcher.RetriedError
+		if errors.As(err, &retriedErr) {
+			result.Retries = retriedErr.Retries
+		}
+		return result, err
 	}
+	rssFeed := fetchResult.Feed
+	result.Warnings = fetchResult.Warnings
+	result.Retries = fetchResult.Retries
 
-	err = db.MarkFeedFetched(context.Background(), nextFeed.ID)
+	*feed, result.URLChange, err = followPermanentRedirect(ctx, db, *feed, fetchResult)
 	if err != nil {
-		return "", err
+		return result, err
 	}
 
-	rssFeed, err := feedfetcher.FetchFeed(context.Background(), nextFeed.Url)
+	var postWarnings []string
+	result.NewPosts, postWarnings, err = storePosts(ctx, db, *feed, rssFeed.Channel.Item)
+	result.Warnings = append(result.Warnings, postWarnings...)
 	if err != nil {
-		return "", err
+		return result, err
 	}
 
-	for _, rssItem := range rssFeed.Channel.Item {
-		rssItemPubDate, err := time.Parse(time.RFC1123, rssItem.PubDate)
+	if rssFeed.Channel.Title != "" {
+		result.FeedName = rssFeed.Channel.Title
+	}
+	return result, nil
+}
+
+// storePosts stores the items of a feed as posts, skipping those already
+// stored, and returns how many were new. Items without a publication date it
+// can read are skipped too, with a warning for each.
+func storePosts(ctx context.Context, db *database.Queries, feed database.Feed, rssItems []feedfetcher.RSSItem) (newPosts int, warnings []string, err error) {
+	ctx, span := tracer.Start(ctx, "store posts", trace.WithAttributes(attribute.Int("gator.feed.items", len(rssItems))))
+	defer func() {
+		span.SetAttributes(attribute.Int("gator.feed.new_posts", newPosts))
+		tracing.End(span, err)
+	}()
+
+	for _, rssItem := range rssItems {
+		rssItemPubDate, err := parsePubDate(rssItem.PubDate)
 		if err != nil {
-			return "", err
+			warnings = append(warnings, fmt.Sprintf("skipped item %q: %v", rssItem.Link, err))
+			continue
 		}
+		description := sanitizeDescription(rssItem, feed.Url)
 		_, err = db.CreatePost(
-			context.Background(),
+			ctx,
 			database.CreatePostParams{
-				ID:          uuid.New(),
-				CreatedAt:   time.Now(),
-				UpdatedAt:   time.Now(),
-				Title:       rssItem.Title,
-				Url:         rssItem.Link,
-				Description: sql.NullString{String: rssItem.Description, Valid: rssItem.Description != ""},
-				PublishedAt: rssItemPubDate,
-				FeedID:      nextFeed.ID,
+				ID:                  uuid.New(),
+				CreatedAt:           time.Now(),
+				UpdatedAt:           time.Now(),
+				Title:               rssItem.Title,
+				Url:                 rssItem.Link,
+				Description:         sql.NullString{String: description, Valid: description != ""},
+				PublishedAt:         rssItemPubDate,
+				FeedID:              feed.ID,
+				OriginalDescription: sql.NullString{String: rssItem.Description, Valid: rssItem.Description != ""},
 			},
 		)
 		if err != nil && !strings.Contains(err.Error(), "posts_url_key") {
-			return "", err
+			return newPosts, warnings, err
+		}
+		if err == nil {
+			newPosts++
+			metrics.Posts.WithLabelValues("inserted").Inc()
+		} else {
+			metrics.Posts.WithLabelValues("skipped").Inc()
+		}
+	}
+	return newPosts, warnings, nil
+}
+
+// pubDateLayouts are the date formats found in feeds: RFC 822 dates as RSS
+// requires, with a numeric or named zone and with or without a leading zero
+// in the day, and RFC 3339 dates as Atom uses.
+var pubDateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04:05 MST",
+	time.RFC3339,
+}
+
+// parsePubDate parses the publication date of an item.
+func parsePubDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range pubDateLayouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unknown publication date format %q", value)
+}
+
+// followPermanentRedirect updates the URL of a feed that permanently moved
+// and records the change. When another feed already uses the new URL, the two
+// are merged: follows, posts and earlier URL changes move over to the existing
+// feed and the moved one is deleted. It returns the feed to store posts in.
+func followPermanentRedirect(ctx context.Context, db *database.Queries, feed database.Feed, fetchResult *feedfetcher.FetchResult) (database.Feed, *database.FeedUrlChange, error) {
+	newUrl := fetchResult.PermanentURL
+	if newUrl == "" || newUrl == feed.Url {
+		return feed, nil, nil
+	}
+
+	statusCode := 0
+	for _, redirect := range fetchResult.Redirects {
+		if !redirect.Permanent() {
+			break
 		}
+		statusCode = redirect.StatusCode
 	}
 
-	return rssFeed.Channel.Title, nil
+	// Everything happens in one transaction, so that a failure part-way
+	// leaves the feeds as they were rather than split across two.
+	movedFeed := feed
+	var urlChange database.FeedUrlChange
+	err := db.InTx(ctx, func(db *database.Queries) error {
+		merged := false
+		existingFeed, err := db.GetFeedByURL(ctx, newUrl)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			movedFeed, err = db.UpdateFeedURL(
+				ctx,
+				database.UpdateFeedURLParams{
+					ID:  feed.ID,
+					Url: newUrl,
+				},
+			)
+			if err != nil {
+				return fmt.Errorf("failed to update feed URL to %s: %v", newUrl, err)
+			}
+		case err != nil:
+			return err
+		default:
+			err = mergeFeeds(ctx, db, feed, existingFeed)
+			if err != nil {
+				return fmt.Errorf("failed to merge feed %s into %s: %v", feed.Name, existingFeed.Name, err)
+			}
+			movedFeed = existingFeed
+			merged = true
+		}
+
+		urlChange, err = db.CreateFeedURLChange(
+			ctx,
+			database.CreateFeedURLChangeParams{
+				ID:         uuid.New(),
+				FeedID:     movedFeed.ID,
+				OldUrl:     feed.Url,
+				NewUrl:     newUrl,
+				StatusCode: int32(statusCode),
+				Merged:     merged,
+				ChangedAt:  time.Now(),
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to record URL change of feed %s: %v", movedFeed.Name, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return feed, nil, err
+	}
+
+	return movedFeed, &urlChange, nil
+}
+
+// mergeFeeds moves everything attached to from over to into, then deletes from.
+func mergeFeeds(ctx context.Context, db *database.Queries, from database.Feed, into database.Feed) error {
+	err := db.MoveFeedFollows(
+		ctx,
+		database.MoveFeedFollowsParams{
+			NewFeedID: into.ID,
+			OldFeedID: from.ID,
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	err = db.MovePosts(
+		ctx,
+		database.MovePostsParams{
+			NewFeedID: into.ID,
+			OldFeedID: from.ID,
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	err = db.MoveFeedURLChanges(
+		ctx,
+		database.MoveFeedURLChangesParams{
+			NewFeedID: into.ID,
+			OldFeedID: from.ID,
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	return db.DeleteFeed(ctx, from.ID)
+}
+
+// sanitizeDescription returns the item description stripped down to safe
+// HTML, with relative URLs resolved against the item link, or against the
+// feed URL when the item has no usable link.
+func sanitizeDescription(rssItem feedfetcher.RSSItem, feedUrl string) string {
+	base, err := url.Parse(rssItem.Link)
+	if err != nil || !base.IsAbs() {
+		base, err = url.Parse(feedUrl)
+		if err != nil {
+			base = nil
+		}
+	}
+	return htmlsanitizer.Sanitize(rssItem.Description, base)
 }
@@ -0,0 +1,80 @@
+package feedscraper
+
+import (
+	"testing"
+	"time"
+
+	feedfetcher "github.com/alancorleto/gator/internal/feed_fetcher"
+)
+
+func TestSanitizeDescription(t *testing.T) {
+	const feedUrl = "http://example.com/blog/feed.xml"
+	const description = `<a href="post">x</a>`
+
+	tests := []struct {
+		name string
+		link string
+		want string
+	}{
+		{
+			name: "relative to the item link",
+			link: "http://posts.example.org/2024/entry",
+			want: `<a href="http://posts.example.org/2024/post" rel="nofollow noopener noreferrer">x</a>`,
+		},
+		{
+			name: "relative to the feed without item link",
+			link: "",
+			want: `<a href="http://example.com/blog/post" rel="nofollow noopener noreferrer">x</a>`,
+		},
+		{
+			name: "relative to the feed with a relative item link",
+			link: "/2024/entry",
+			want: `<a href="http://example.com/blog/post" rel="nofollow noopener noreferrer">x</a>`,
+		},
+		{
+			name: "relative to the feed with an invalid item link",
+			link: "http://[::1",
+			want: `<a href="http://example.com/blog/post" rel="nofollow noopener noreferrer">x</a>`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			item := feedfetcher.RSSItem{Link: test.link, Description: description}
+			got := sanitizeDescription(item, feedUrl)
+			if got != test.want {
+				t.Errorf("sanitizeDescription() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParsePubDate(t *testing.T) {
+	want := time.Date(2024, 3, 5, 14, 30, 0, 0, time.UTC)
+	for _, value := range []string{
+		"Tue, 05 Mar 2024 14:30:00 +0000",
+		"Tue, 05 Mar 2024 14:30:00 UTC",
+		"Tue, 05 Mar 2024 14:30:00 GMT",
+		"Tue, 5 Mar 2024 14:30:00 +0000",
+		"Tue, 05 Mar 2024 15:30:00 +0100",
+		" Tue, 05 Mar 2024 14:30:00 +0000\n",
+		"5 Mar 2024 14:30:00 +0000",
+		"2024-03-05T14:30:00Z",
+		"2024-03-05T16:30:00.000+02:00",
+	} {
+		got, err := parsePubDate(value)
+		if err != nil {
+			t.Errorf("parsePubDate(%q) failed: %v", value, err)
+			continue
+		}
+		if !got.Equal(want) {
+			t.Errorf("parsePubDate(%q) = %v, want %v", value, got, want)
+		}
+	}
+
+	for _, value := range []string{"", "yesterday", "2024-03-05", "Tue, 05 Mar 2024"} {
+		if _, err := parsePubDate(value); err == nil {
+			t.Errorf("parsePubDate(%q) succeeded", value)
+		}
+	}
+}
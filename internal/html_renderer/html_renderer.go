@@ -0,0 +1,357 @@
+package htmlrenderer
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const minWrapWidth = 20
+
+type list struct {
+	ordered bool
+	counter int
+}
+
+type renderer struct {
+	width int
+	lines []string
+	links []string
+
+	inline strings.Builder
+	// linkTexts collects the text of the links being rendered, apart from
+	// inline, which is flushed whenever a block starts inside a link.
+	linkTexts []*strings.Builder
+	indent    []string
+	lists     []*list
+
+	// marker is the list item marker ("• ", "1. ") written in place of the
+	// indentation at markerDepth on the first line of the next paragraph.
+	marker      string
+	markerDepth int
+
+	preDepth   int
+	lastInList bool
+}
+
+// Render converts an HTML fragment into plain text for the terminal, wrapped
+// to width columns. Paragraphs, headings, lists, quotes and code blocks keep
+// their structure, emphasis is shown as _em_, *strong* and `code`, and links
+// become numbered footnotes listed after the text. A width of zero or less
+// disables wrapping.
+func Render(content string, width int) string {
+	context := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(content), context)
+	if err != nil {
+		return content
+	}
+
+	r := &renderer{width: width}
+	for _, node := range nodes {
+		r.render(node)
+	}
+	r.flush()
+
+	if len(r.links) > 0 {
+		r.lines = append(r.lines, "")
+		for i, link := range r.links {
+			r.lines = append(r.lines, fmt.Sprintf("[%d]: %s", i+1, link))
+		}
+	}
+
+	return strings.Join(r.lines, "\n")
+}
+
+func (r *renderer) render(node *html.Node) {
+	switch node.Type {
+	case html.TextNode:
+		r.writeText(StripControl(node.Data))
+		return
+	case html.ElementNode:
+	default:
+		r.renderChildren(node)
+		return
+	}
+
+	switch node.DataAtom {
+	case atom.Script, atom.Style, atom.Head, atom.Iframe, atom.Noscript, atom.Object, atom.Embed, atom.Template, atom.Svg:
+		return
+
+	case atom.Br:
+		r.write("\n")
+
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		level := int(node.Data[1] - '0')
+		r.flush()
+		r.write(strings.Repeat("#", level) + " ")
+		r.renderChildren(node)
+		r.flush()
+
+	case atom.Ul, atom.Ol:
+		r.flush()
+		if len(r.lists) == 0 {
+			r.lastInList = false
+		}
+		r.lists = append(r.lists, &list{ordered: node.DataAtom == atom.Ol})
+		r.renderChildren(node)
+		r.flush()
+		r.lists = r.lists[:len(r.lists)-1]
+
+	case atom.Li:
+		r.flush()
+		marker := "• "
+		if len(r.lists) > 0 {
+			current := r.lists[len(r.lists)-1]
+			current.counter++
+			if current.ordered {
+				marker = fmt.Sprintf("%d. ", current.counter)
+			}
+		}
+		r.indent = append(r.indent, strings.Repeat(" ", len([]rune(marker))))
+		r.marker = marker
+		r.markerDepth = len(r.indent)
+		r.renderChildren(node)
+		r.flush()
+		r.marker = ""
+		r.indent = r.indent[:len(r.indent)-1]
+
+	case atom.Blockquote:
+		r.flush()
+		r.indent = append(r.indent, "> ")
+		r.renderChildren(node)
+		r.flush()
+		r.indent = r.indent[:len(r.indent)-1]
+
+	case atom.Pre:
+		r.flush()
+		r.preDepth++
+		r.renderChildren(node)
+		r.preDepth--
+		r.flushPreformatted()
+
+	case atom.Hr:
+		r.flush()
+		r.startBlock(false)
+		_, prefix := r.prefixes()
+		r.lines = append(r.lines, prefix+strings.Repeat("─", r.ruleWidth()))
+
+	case atom.Code, atom.Kbd, atom.Samp:
+		r.wrapInline(node, "`")
+
+	case atom.Em, atom.I:
+		r.wrapInline(node, "_")
+
+	case atom.Strong, atom.B:
+		r.wrapInline(node, "*")
+
+	case atom.A:
+		r.renderLink(node)
+
+	case atom.Img:
+		if alt := strings.TrimSpace(attribute(node, "alt")); alt != "" {
+			r.writeText("[image: " + alt + "]")
+		}
+
+	case atom.P, atom.Div, atom.Section, atom.Article, atom.Header, atom.Footer, atom.Main, atom.Aside, atom.Nav,
+		atom.Figure, atom.Figcaption, atom.Table, atom.Tr, atom.Dl, atom.Dt, atom.Dd, atom.Details, atom.Summary:
+		r.flush()
+		r.renderChildren(node)
+		r.flush()
+
+	case atom.Td, atom.Th:
+		r.renderChildren(node)
+		r.writeText(" ")
+
+	default:
+		r.renderChildren(node)
+	}
+}
+
+func (r *renderer) renderChildren(node *html.Node) {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		r.render(child)
+	}
+}
+
+func (r *renderer) wrapInline(node *html.Node, delimiter string) {
+	if r.preDepth > 0 {
+		r.renderChildren(node)
+		return
+	}
+	r.writeText(delimiter)
+	r.renderChildren(node)
+	r.write(delimiter)
+}
+
+func (r *renderer) renderLink(node *html.Node) {
+	linkText := &strings.Builder{}
+	r.linkTexts = append(r.linkTexts, linkText)
+	r.renderChildren(node)
+	r.linkTexts = r.linkTexts[:len(r.linkTexts)-1]
+	text := strings.TrimSpace(linkText.String())
+
+	href := strings.TrimSpace(attribute(node, "href"))
+	if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+		return
+	}
+	if text == href {
+		return
+	}
+
+	r.links = append(r.links, href)
+	r.write(fmt.Sprintf("[%d]", len(r.links)))
+}
+
+// write appends text to the current paragraph and to the text of the links
+// being rendered.
+func (r *renderer) write(text string) {
+	r.inline.WriteString(text)
+	for _, linkText := range r.linkTexts {
+		linkText.WriteString(text)
+	}
+}
+
+// writeText appends text to the current paragraph, collapsing whitespace the
+// way a browser would unless inside a preformatted block.
+func (r *renderer) writeText(text string) {
+	if r.preDepth > 0 {
+		r.write(text)
+		return
+	}
+
+	collapsed := strings.Join(strings.FieldsFunc(text, unicode.IsSpace), " ")
+	if text != "" && unicode.IsSpace([]rune(text)[0]) {
+		collapsed = " " + collapsed
+	}
+	if collapsed != " " && text != "" && unicode.IsSpace([]rune(text)[len([]rune(text))-1]) {
+		collapsed += " "
+	}
+
+	current := r.inline.String()
+	if strings.HasPrefix(collapsed, " ") && (current == "" || strings.HasSuffix(current, " ") || strings.HasSuffix(current, "\n")) {
+		collapsed = collapsed[1:]
+	}
+	r.write(collapsed)
+}
+
+// startBlock separates a new block from the previous one with a blank line,
+// except between consecutive list items.
+func (r *renderer) startBlock(inList bool) {
+	if len(r.lines) > 0 && !(inList && r.lastInList) {
+		r.lines = append(r.lines, "")
+	}
+	r.lastInList = inList
+}
+
+func (r *renderer) prefixes() (string, string) {
+	prefix := strings.Join(r.indent, "")
+	if r.marker == "" || r.markerDepth > len(r.indent) {
+		return prefix, prefix
+	}
+
+	firstPrefix := strings.Join(r.indent[:r.markerDepth-1], "") + r.marker + strings.Join(r.indent[r.markerDepth:], "")
+	r.marker = ""
+	return firstPrefix, prefix
+}
+
+// flush wraps the pending paragraph and appends it to the output.
+func (r *renderer) flush() {
+	text := r.inline.String()
+	r.inline.Reset()
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+
+	r.startBlock(len(r.lists) > 0)
+	firstPrefix, prefix := r.prefixes()
+
+	first := true
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		for _, wrapped := range wrap(strings.TrimSpace(line), r.wrapWidth(prefix)) {
+			linePrefix := prefix
+			if first {
+				linePrefix = firstPrefix
+				first = false
+			}
+			r.lines = append(r.lines, strings.TrimRight(linePrefix+wrapped, " "))
+		}
+	}
+}
+
+// flushPreformatted appends the pending code block without wrapping it.
+func (r *renderer) flushPreformatted() {
+	text := strings.Trim(r.inline.String(), "\n")
+	r.inline.Reset()
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+
+	r.startBlock(len(r.lists) > 0)
+	_, prefix := r.prefixes()
+	for _, line := range strings.Split(text, "\n") {
+		r.lines = append(r.lines, strings.TrimRight(prefix+"    "+line, " "))
+	}
+}
+
+func (r *renderer) wrapWidth(prefix string) int {
+	if r.width <= 0 {
+		return 0
+	}
+	return max(r.width-len([]rune(prefix)), minWrapWidth)
+}
+
+// ruleWidth is the width of a horizontal rule, at least one column however
+// deeply it is indented.
+func (r *renderer) ruleWidth() int {
+	if r.width <= 0 {
+		return minWrapWidth
+	}
+	return max(r.width-len([]rune(strings.Join(r.indent, ""))), 1)
+}
+
+// wrap breaks a line of text into lines of at most width runes. Words longer
+// than width are kept whole. A width of zero or less disables wrapping.
+func wrap(text string, width int) []string {
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return []string{""}
+	}
+	if width <= 0 {
+		return []string{strings.Join(words, " ")}
+	}
+
+	var lines []string
+	line := words[0]
+	for _, word := range words[1:] {
+		if len([]rune(line))+1+len([]rune(word)) > width {
+			lines = append(lines, line)
+			line = word
+		} else {
+			line += " " + word
+		}
+	}
+	return append(lines, line)
+}
+
+func attribute(node *html.Node, key string) string {
+	for _, attr := range node.Attr {
+		if attr.Key == key {
+			return StripControl(attr.Val)
+		}
+	}
+	return ""
+}
+
+// StripControl removes control characters other than newlines and tabs from
+// text, so that feed content cannot send escape sequences to the terminal.
+func StripControl(text string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) && r != '\n' && r != '\t' {
+			return -1
+		}
+		return r
+	}, text)
+}
@@ -0,0 +1,114 @@
+package htmlrenderer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		width   int
+		want    string
+	}{
+		{
+			name:    "paragraphs",
+			content: "<p>Hello</p><p>World</p>",
+			want:    "Hello\n\nWorld",
+		},
+		{
+			name:    "emphasis",
+			content: "<p><em>a</em> <strong>b</strong> <code>c</code></p>",
+			want:    "_a_ *b* `c`",
+		},
+		{
+			name:    "link",
+			content: `Read <a href="http://x/post">the post</a>.`,
+			want:    "Read the post[1].\n\n[1]: http://x/post",
+		},
+		{
+			name:    "link showing its URL",
+			content: `<a href="http://x/post">http://x/post</a>`,
+			want:    "http://x/post",
+		},
+		{
+			name:    "link around a block",
+			content: `Hello <a href="http://x"><div>World</div></a>`,
+			want:    "Hello\n\nWorld\n\n[1]\n\n[1]: http://x",
+		},
+		{
+			name:    "link around a paragraph",
+			content: `<a href="http://x">link<p>para</p></a>`,
+			want:    "link\n\npara\n\n[1]\n\n[1]: http://x",
+		},
+		{
+			name:    "link around a block showing its URL",
+			content: `<a href="http://x"><div>http://x</div></a>`,
+			want:    "http://x",
+		},
+		{
+			name:    "control characters",
+			content: "<p>a\x1b[2Jb&#27;[31mc\x07d</p><pre>x\x1by\tz</pre>",
+			want:    "a[2Jb[31mcd\n\n    xy\tz",
+		},
+		{
+			name:    "control characters in a link",
+			content: "<a href=\"http://x/\x1b]8;;\">text</a><img alt=\"\x1b[5mimage\">",
+			want:    "text[1][image: [5mimage]\n\n[1]: http://x/]8;;",
+		},
+		{
+			name:    "rule",
+			content: "<p>a</p><hr><p>b</p>",
+			width:   10,
+			want:    "a\n\n──────────\n\nb",
+		},
+		{
+			name:    "rule without width",
+			content: "<hr>",
+			width:   -1,
+			want:    strings.Repeat("─", minWrapWidth),
+		},
+		{
+			name:    "quote",
+			content: "<blockquote><p>a</p><blockquote>b</blockquote></blockquote>",
+			want:    "> a\n\n> > b",
+		},
+		{
+			name:    "rule in a quote",
+			content: "<blockquote><hr></blockquote>",
+			width:   10,
+			want:    "> ────────",
+		},
+		{
+			name:    "rule narrower than its quotes",
+			content: strings.Repeat("<blockquote>", 41) + "<hr>" + strings.Repeat("</blockquote>", 41),
+			want:    strings.Repeat("> ", 41) + "─",
+		},
+		{
+			name:    "rule in a narrow quote",
+			content: "<blockquote><blockquote><hr></blockquote></blockquote>",
+			width:   3,
+			want:    "> > ─",
+		},
+		{
+			name:    "narrow width wraps at the minimum width",
+			content: "<p>one two three four five six</p>",
+			width:   3,
+			want:    "one two three four\nfive six",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			width := test.width
+			if width == 0 {
+				width = 80
+			}
+			got := Render(test.content, width)
+			if got != test.want {
+				t.Errorf("Render(%q) = %q, want %q", test.content, got, test.want)
+			}
+		})
+	}
+}
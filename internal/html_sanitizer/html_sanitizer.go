@@ -0,0 +1,236 @@
+package htmlsanitizer
+
+import (
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// allowedElements maps every element that survives sanitization to the
+// attributes it may keep.
+var allowedElements = map[atom.Atom][]string{
+	atom.A:          {"href", "title"},
+	atom.Abbr:       {"title"},
+	atom.B:          nil,
+	atom.Blockquote: {"cite"},
+	atom.Br:         nil,
+	atom.Caption:    nil,
+	atom.Cite:       nil,
+	atom.Code:       nil,
+	atom.Dd:         nil,
+	atom.Del:        nil,
+	atom.Details:    nil,
+	atom.Div:        nil,
+	atom.Dl:         nil,
+	atom.Dt:         nil,
+	atom.Em:         nil,
+	atom.Figcaption: nil,
+	atom.Figure:     nil,
+	atom.H1:         nil,
+	atom.H2:         nil,
+	atom.H3:         nil,
+	atom.H4:         nil,
+	atom.H5:         nil,
+	atom.H6:         nil,
+	atom.Hr:         nil,
+	atom.I:          nil,
+	atom.Img:        {"src", "alt", "title", "width", "height"},
+	atom.Ins:        nil,
+	atom.Kbd:        nil,
+	atom.Li:         nil,
+	atom.Mark:       nil,
+	atom.Ol:         {"start"},
+	atom.P:          nil,
+	atom.Pre:        nil,
+	atom.Q:          {"cite"},
+	atom.S:          nil,
+	atom.Samp:       nil,
+	atom.Small:      nil,
+	atom.Span:       nil,
+	atom.Strong:     nil,
+	atom.Sub:        nil,
+	atom.Summary:    nil,
+	atom.Sup:        nil,
+	atom.Table:      nil,
+	atom.Tbody:      nil,
+	atom.Td:         {"colspan", "rowspan"},
+	atom.Tfoot:      nil,
+	atom.Th:         {"colspan", "rowspan"},
+	atom.Thead:      nil,
+	atom.Time:       {"datetime"},
+	atom.Tr:         nil,
+	atom.U:          nil,
+	atom.Ul:         nil,
+}
+
+// droppedElements are removed together with everything inside them. Any
+// other element that is not allowed is unwrapped, keeping its content.
+var droppedElements = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Iframe:   true,
+	atom.Frame:    true,
+	atom.Frameset: true,
+	atom.Object:   true,
+	atom.Embed:    true,
+	atom.Applet:   true,
+	atom.Noscript: true,
+	atom.Template: true,
+	atom.Svg:      true,
+	atom.Math:     true,
+	atom.Form:     true,
+	atom.Input:    true,
+	atom.Button:   true,
+	atom.Select:   true,
+	atom.Textarea: true,
+	atom.Head:     true,
+	atom.Title:    true,
+	atom.Meta:     true,
+	atom.Link:     true,
+	atom.Base:     true,
+}
+
+// urlAttributes are the attributes whose values are URLs, and the schemes
+// each one accepts.
+var urlAttributes = map[string][]string{
+	"href": {"http", "https", "mailto"},
+	"src":  {"http", "https"},
+	"cite": {"http", "https"},
+}
+
+// Sanitize returns content with every element and attribute outside a fixed
+// allow-list removed: scripts, frames, forms, inline styles, event handlers
+// and tracking pixels are stripped, and relative URLs are resolved against
+// base. Links are rewritten to open without a referrer. A nil base drops
+// relative URLs instead.
+func Sanitize(content string, base *url.URL) string {
+	context := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(content), context)
+	if err != nil {
+		return html.EscapeString(content)
+	}
+
+	var sanitized strings.Builder
+	for _, node := range nodes {
+		writeNode(&sanitized, node, base)
+	}
+	return strings.TrimSpace(sanitized.String())
+}
+
+func writeNode(sanitized *strings.Builder, node *html.Node, base *url.URL) {
+	switch node.Type {
+	case html.TextNode:
+		sanitized.WriteString(html.EscapeString(node.Data))
+		return
+	case html.ElementNode:
+	default:
+		writeChildren(sanitized, node, base)
+		return
+	}
+
+	if droppedElements[node.DataAtom] || isTrackingPixel(node) {
+		return
+	}
+
+	allowedAttributes, allowed := allowedElements[node.DataAtom]
+	if !allowed {
+		writeChildren(sanitized, node, base)
+		return
+	}
+
+	attributes := sanitizeAttributes(node, allowedAttributes, base)
+	if node.DataAtom == atom.Img && attributes["src"] == "" {
+		return
+	}
+
+	sanitized.WriteString("<" + node.Data)
+	for _, key := range allowedAttributes {
+		if value, ok := attributes[key]; ok {
+			sanitized.WriteString(" " + key + `="` + html.EscapeString(value) + `"`)
+		}
+	}
+	if node.DataAtom == atom.A {
+		sanitized.WriteString(` rel="nofollow noopener noreferrer"`)
+	}
+	sanitized.WriteString(">")
+
+	if isVoidElement(node.DataAtom) {
+		return
+	}
+
+	writeChildren(sanitized, node, base)
+	sanitized.WriteString("</" + node.Data + ">")
+}
+
+func writeChildren(sanitized *strings.Builder, node *html.Node, base *url.URL) {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		writeNode(sanitized, child, base)
+	}
+}
+
+func sanitizeAttributes(node *html.Node, allowedAttributes []string, base *url.URL) map[string]string {
+	attributes := make(map[string]string)
+	for _, attr := range node.Attr {
+		if attr.Namespace != "" || !slices.Contains(allowedAttributes, attr.Key) {
+			continue
+		}
+
+		value := strings.TrimSpace(attr.Val)
+		if schemes, isURL := urlAttributes[attr.Key]; isURL {
+			resolved, ok := resolveURL(value, base, schemes)
+			if !ok {
+				continue
+			}
+			value = resolved
+		}
+		attributes[attr.Key] = value
+	}
+	return attributes
+}
+
+// resolveURL resolves a possibly relative URL against base and reports
+// whether the result uses one of the accepted schemes.
+func resolveURL(rawURL string, base *url.URL, schemes []string) (string, bool) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", false
+	}
+	if !parsed.IsAbs() {
+		if base == nil {
+			return "", false
+		}
+		parsed = base.ResolveReference(parsed)
+	}
+
+	if !slices.Contains(schemes, strings.ToLower(parsed.Scheme)) {
+		return "", false
+	}
+	return parsed.String(), true
+}
+
+// isTrackingPixel reports whether node is an image of at most one pixel,
+// which feeds embed to track readers.
+func isTrackingPixel(node *html.Node) bool {
+	if node.DataAtom != atom.Img {
+		return false
+	}
+
+	for _, attr := range node.Attr {
+		if attr.Key != "width" && attr.Key != "height" {
+			continue
+		}
+		size, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(attr.Val), "px"))
+		if err == nil && size <= 1 {
+			return true
+		}
+	}
+	return false
+}
+
+func isVoidElement(a atom.Atom) bool {
+	return a == atom.Br || a == atom.Hr || a == atom.Img
+}
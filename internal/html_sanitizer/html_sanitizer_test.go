@@ -0,0 +1,137 @@
+package htmlsanitizer
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestSanitize(t *testing.T) {
+	base, err := url.Parse("http://example.com/posts/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "allowed markup",
+			content: `<p>a <em>b</em> <code>c</code></p>`,
+			want:    `<p>a <em>b</em> <code>c</code></p>`,
+		},
+		{
+			name:    "script, iframe and style dropped with their content",
+			content: `<p>a</p><script>alert(1)</script><iframe src="http://x"><p>in</p></iframe><style>p { color: red }</style>b`,
+			want:    `<p>a</p>b`,
+		},
+		{
+			name:    "script inside svg",
+			content: `<svg><script>alert(1)</script></svg>text`,
+			want:    `text`,
+		},
+		{
+			name:    "event handlers, style and class stripped",
+			content: `<p onclick="steal()" onmouseover="steal()" style="color: red" class="c">a</p>`,
+			want:    `<p>a</p>`,
+		},
+		{
+			name:    "javascript href",
+			content: `<a href="javascript:alert(1)">x</a>`,
+			want:    `<a rel="nofollow noopener noreferrer">x</a>`,
+		},
+		{
+			name:    "obfuscated javascript href",
+			content: `<a href=" JaVaScRiPt:alert(1)">x</a>`,
+			want:    `<a rel="nofollow noopener noreferrer">x</a>`,
+		},
+		{
+			name:    "data href",
+			content: `<a href="data:text/html,<script>alert(1)</script>">x</a>`,
+			want:    `<a rel="nofollow noopener noreferrer">x</a>`,
+		},
+		{
+			name:    "data image",
+			content: `<img src="data:image/png;base64,AAAA" alt="x">`,
+			want:    ``,
+		},
+		{
+			name:    "mailto href",
+			content: `<a href="mailto:me@example.com">mail</a>`,
+			want:    `<a href="mailto:me@example.com" rel="nofollow noopener noreferrer">mail</a>`,
+		},
+		{
+			name:    "relative URLs resolved",
+			content: `<a href="../2">x</a><img src="/img.png" alt="i"><blockquote cite="quote">q</blockquote>`,
+			want:    `<a href="http://example.com/2" rel="nofollow noopener noreferrer">x</a><img src="http://example.com/img.png" alt="i"><blockquote cite="http://example.com/posts/quote">q</blockquote>`,
+		},
+		{
+			name:    "protocol-relative URL",
+			content: `<a href="//cdn.example.org/x">x</a>`,
+			want:    `<a href="http://cdn.example.org/x" rel="nofollow noopener noreferrer">x</a>`,
+		},
+		{
+			name:    "tracking pixels removed",
+			content: `<img src="http://t/a.gif" width="1" height="1"><img src="http://t/b.gif" width="1px"><img src="http://t/c.gif" height="0"><img src="http://t/d.gif" width="100">`,
+			want:    `<img src="http://t/d.gif" width="100">`,
+		},
+		{
+			name:    "links open without referrer",
+			content: `<a href="http://x/" rel="opener" target="_blank">x</a>`,
+			want:    `<a href="http://x/" rel="nofollow noopener noreferrer">x</a>`,
+		},
+		{
+			name:    "attribute values escaped",
+			content: `<a href="http://x/" title='"><script>alert(1)</script>'>x</a>`,
+			want:    `<a href="http://x/" title="&#34;&gt;&lt;script&gt;alert(1)&lt;/script&gt;" rel="nofollow noopener noreferrer">x</a>`,
+		},
+		{
+			name:    "text escaped",
+			content: `a < b & c > d`,
+			want:    `a &lt; b &amp; c &gt; d`,
+		},
+		{
+			name:    "unknown elements unwrapped",
+			content: `<custom><b>kept</b></custom><font color="red">f</font>`,
+			want:    `<b>kept</b>f`,
+		},
+		{
+			name:    "nested elements",
+			content: `<ul><li><p>a <a href="http://x/"><strong>b</strong></a></p></li></ul>`,
+			want:    `<ul><li><p>a <a href="http://x/" rel="nofollow noopener noreferrer"><strong>b</strong></a></p></li></ul>`,
+		},
+		{
+			name:    "misnested elements",
+			content: `<div><p><b>bold <i>both</b> italic</i></p>`,
+			want:    `<div><p><b>bold <i>both</i></b><i> italic</i></p></div>`,
+		},
+		{
+			name:    "unclosed elements",
+			content: `<p>unclosed <em>em`,
+			want:    `<p>unclosed <em>em</em></p>`,
+		},
+		{
+			name:    "unterminated script",
+			content: `a<script>alert(1)`,
+			want:    `a`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := Sanitize(test.content, base)
+			if got != test.want {
+				t.Errorf("Sanitize(%q)\n got %q\nwant %q", test.content, got, test.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeWithoutBase(t *testing.T) {
+	got := Sanitize(`<a href="rel">x</a><img src="a.png"><a href="http://x/">y</a>`, nil)
+	want := `<a rel="nofollow noopener noreferrer">x</a><a href="http://x/" rel="nofollow noopener noreferrer">y</a>`
+	if got != want {
+		t.Errorf("Sanitize() = %q, want %q", got, want)
+	}
+}
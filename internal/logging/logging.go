@@ -0,0 +1,38 @@
+// Package logging builds the logger gator writes its diagnostics with, as
+// opposed to the output of commands.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// New returns a logger writing to w records of the given level and above,
+// one of "debug", "info", "warn" or "error", in the given format, "text" or
+// "json". Empty values select "info" and "text".
+func New(w io.Writer, level string, format string) (*slog.Logger, error) {
+	var slogLevel slog.Level
+	if level != "" {
+		err := slogLevel.UnmarshalText([]byte(level))
+		if err != nil {
+			return nil, fmt.Errorf("invalid log level '%s': must be debug, info, warn or error", level)
+		}
+	}
+
+	options := &slog.HandlerOptions{Level: slogLevel}
+	switch strings.ToLower(format) {
+	case "", FormatText:
+		return slog.New(slog.NewTextHandler(w, options)), nil
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, options)), nil
+	default:
+		return nil, fmt.Errorf("invalid log format '%s': must be %s or %s", format, FormatText, FormatJSON)
+	}
+}
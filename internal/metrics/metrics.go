@@ -0,0 +1,88 @@
+// Package metrics defines the Prometheus metrics of the aggregator. They are
+// registered with the default registry, next to the Go runtime and process
+// metrics, and served by Handler.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "gator"
+
+var (
+	// Fetches counts fetches of feeds by outcome, once per fetch whatever
+	// the number of retries. See feedfetcher for the outcomes.
+	Fetches = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "fetches_total",
+		Help:      "Feed fetches by outcome.",
+	}, []string{"outcome"})
+
+	// HTTPResponses counts the responses to feed requests by status class,
+	// such as "2xx", retries included.
+	HTTPResponses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_responses_total",
+		Help:      "HTTP responses to feed requests by status class.",
+	}, []string{"class"})
+
+	FetchDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "fetch_duration_seconds",
+		Help:      "Time taken to fetch and parse a feed, retries included.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+	})
+
+	FetchRetries = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "fetch_retries_total",
+		Help:      "Feed fetches retried after a transient failure.",
+	})
+
+	// DownloadedBytes counts response bodies as transferred, before they
+	// are decompressed.
+	DownloadedBytes = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "downloaded_bytes_total",
+		Help:      "Bytes of feed response bodies downloaded.",
+	})
+
+	// ParseErrors counts feeds that could not be parsed, by reason: "limit"
+	// for feeds exceeding the parser limits and "malformed" for the others.
+	ParseErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "parse_errors_total",
+		Help:      "Feeds that could not be parsed, by reason.",
+	}, []string{"reason"})
+
+	LenientParses = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "lenient_parses_total",
+		Help:      "Feeds that were not well-formed XML but were parsed leniently.",
+	})
+
+	// Posts counts the items of scraped feeds by result: "inserted" for new
+	// posts and "skipped" for posts that were already stored, which are left
+	// as they are.
+	Posts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "posts_total",
+		Help:      "Items of scraped feeds by result.",
+	}, []string{"result"})
+
+	SchedulerLag = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "scheduler_lag_seconds",
+		Help:      "How late rounds of agg start compared to their schedule.",
+		Buckets:   []float64{0.01, 0.1, 0.5, 1, 5, 15, 30, 60, 300},
+	})
+)
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
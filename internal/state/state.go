@@ -1,11 +1,17 @@
 package state
 
 import (
+	"log/slog"
+
 	config "github.com/alancorleto/gator/internal/config"
 	database "github.com/alancorleto/gator/internal/database"
+	feedfetcher "github.com/alancorleto/gator/internal/feed_fetcher"
 )
 
 type State struct {
-	Config *config.Config
-	Db     *database.Queries
+	Config  *config.Config
+	Db      *database.Queries
+	Fetcher *feedfetcher.Fetcher
+	// Logger writes diagnostics to stderr, apart from the output of commands.
+	Logger *slog.Logger
 }
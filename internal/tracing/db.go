@@ -0,0 +1,110 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
+
+	database "github.com/alancorleto/gator/internal/database"
+)
+
+var dbTracer = otel.Tracer("github.com/alancorleto/gator/internal/database")
+
+// tracedDB runs the queries of database.Queries in spans named after them.
+type tracedDB struct {
+	db database.DBTX
+}
+
+// WrapDB returns db with each statement run in a span named after its sqlc
+// query, such as "CreatePost". The span covers running the statement, not
+// reading the rows of a query returning many. Statements of the transactions
+// started through Queries.InTx are traced as well.
+func WrapDB(db *sql.DB) database.DBTX {
+	return tracedDB{db: db}
+}
+
+func (db tracedDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	beginner, ok := db.db.(*sql.DB)
+	if !ok {
+		return nil, errors.New("cannot start a transaction within a transaction")
+	}
+	return beginner.BeginTx(ctx, opts)
+}
+
+func (db tracedDB) WrapTx(tx *sql.Tx) database.DBTX {
+	return tracedDB{db: tx}
+}
+
+func (db tracedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := startQuery(ctx, query)
+	result, err := db.db.ExecContext(ctx, query, args...)
+	endQuery(span, err)
+	return result, err
+}
+
+func (db tracedDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	ctx, span := startQuery(ctx, query)
+	stmt, err := db.db.PrepareContext(ctx, query)
+	endQuery(span, err)
+	return stmt, err
+}
+
+func (db tracedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := startQuery(ctx, query)
+	rows, err := db.db.QueryContext(ctx, query, args...)
+	endQuery(span, err)
+	return rows, err
+}
+
+func (db tracedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, span := startQuery(ctx, query)
+	row := db.db.QueryRowContext(ctx, query, args...)
+	// sql.ErrNoRows only surfaces when scanning and is not a failure of the
+	// statement.
+	endQuery(span, row.Err())
+	return row
+}
+
+func startQuery(ctx context.Context, query string) (context.Context, trace.Span) {
+	name := queryName(query)
+	return dbTracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemNamePostgreSQL,
+			semconv.DBOperationName(name),
+			semconv.DBQueryText(query),
+		),
+	)
+}
+
+// endQuery ends the span of a statement. Unique violations are not recorded
+// as errors: they are how posts that are already stored get skipped.
+func endQuery(span trace.Span, err error) {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		span.SetAttributes(semconv.DBResponseStatusCode(string(pqErr.Code)))
+		if pqErr.Code == "23505" {
+			span.End()
+			return
+		}
+	}
+	End(span, err)
+}
+
+// queryName returns the name sqlc gives the query in its leading comment,
+// "-- name: CreatePost :one", or "query" when there is none.
+func queryName(query string) string {
+	line, _, _ := strings.Cut(query, "\n")
+	rest, ok := strings.CutPrefix(line, "-- name: ")
+	if !ok {
+		return "query"
+	}
+	name, _, _ := strings.Cut(rest, " ")
+	return name
+}
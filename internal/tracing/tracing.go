@@ -0,0 +1,128 @@
+// Package tracing sets up OpenTelemetry tracing. The spans recorded around
+// fetching feeds and storing their posts are exported with OTLP or written
+// as JSON, or not recorded at all when tracing is off.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+const serviceName = "gator"
+
+// Options selects where spans go.
+type Options struct {
+	// Exporter is "none", "stdout" or "otlp". Empty means "none".
+	Exporter string
+	// Endpoint is the URL spans are sent to by the OTLP exporter, such as
+	// "http://localhost:4318". By default, the OTEL_EXPORTER_OTLP_ENDPOINT
+	// environment variable or the local collector.
+	Endpoint string
+	// Output is where the stdout exporter writes. It defaults to os.Stderr,
+	// so that spans do not mix with the output of commands.
+	Output io.Writer
+	// SpanExporter takes the place of Exporter when set, such as a
+	// tracetest.InMemoryExporter in tests. Spans are then exported as soon
+	// as they end instead of in batches.
+	SpanExporter sdktrace.SpanExporter
+	// Logger reports spans that could not be exported.
+	Logger *slog.Logger
+}
+
+// Start installs the tracer provider of options as the global one, which
+// the tracers of gator's packages use. The returned function exports the
+// spans still buffered and stops the provider.
+func Start(ctx context.Context, options Options) (func(context.Context) error, error) {
+	exporter := options.SpanExporter
+	exportNow := exporter != nil
+	if exporter == nil {
+		var err error
+		exporter, err = newExporter(ctx, options)
+		if err != nil {
+			return nil, err
+		}
+		if exporter == nil {
+			return func(context.Context) error { return nil }, nil
+		}
+	}
+
+	// Variables such as OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES
+	// override the defaults.
+	res, err := resource.New(
+		ctx,
+		resource.WithSchemaURL(semconv.SchemaURL),
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+		resource.WithTelemetrySDK(),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe the traced service: %v", err)
+	}
+
+	processor := sdktrace.WithBatcher(exporter)
+	if exportNow {
+		processor = sdktrace.WithSyncer(exporter)
+	}
+	provider := sdktrace.NewTracerProvider(processor, sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	if options.Logger != nil {
+		otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+			options.Logger.Warn("tracing failed", "error", err)
+		}))
+	}
+
+	return provider.Shutdown, nil
+}
+
+func newExporter(ctx context.Context, options Options) (sdktrace.SpanExporter, error) {
+	switch strings.ToLower(options.Exporter) {
+	case "", ExporterNone:
+		return nil, nil
+	case ExporterStdout:
+		output := options.Output
+		if output == nil {
+			output = os.Stderr
+		}
+		return stdouttrace.New(stdouttrace.WithWriter(output))
+	case ExporterOTLP:
+		var otlpOptions []otlptracehttp.Option
+		if options.Endpoint != "" {
+			otlpOptions = append(otlpOptions, otlptracehttp.WithEndpointURL(options.Endpoint))
+		}
+		exporter, err := otlptracehttp.New(ctx, otlpOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OTLP exporter: %v", err)
+		}
+		return exporter, nil
+	default:
+		return nil, fmt.Errorf("invalid tracing exporter '%s': must be %s, %s or %s", options.Exporter, ExporterNone, ExporterStdout, ExporterOTLP)
+	}
+}
+
+// End ends span, recording err as its error when not nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
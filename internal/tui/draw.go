@@ -0,0 +1,224 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	htmlrenderer "github.com/alancorleto/gator/internal/html_renderer"
+)
+
+const (
+	enterAltScreen = "\x1b[?1049h"
+	exitAltScreen  = "\x1b[?1049l"
+	hideCursor     = "\x1b[?25l"
+	showCursor     = "\x1b[?25h"
+	clearScreen    = "\x1b[2J"
+
+	styleReset   = "\x1b[0m"
+	styleBold    = "\x1b[1m"
+	styleDim     = "\x1b[2m"
+	styleReverse = "\x1b[7m"
+
+	maxFeedsPaneWidth = 30
+	helpLine          = "Tab: switch pane  j/k: move  Enter: open  n/p: next/prev post  r: reload  q: quit"
+)
+
+// Layout: a title bar on the first row, a status bar on the last one, the
+// feeds pane on the left, and the post list above the reading pane on the
+// right.
+func (r *reader) feedsWidth() int {
+	return min(maxFeedsPaneWidth, r.width/4)
+}
+
+func (r *reader) rightWidth() int {
+	return max(r.width-r.feedsWidth()-1, 0)
+}
+
+func (r *reader) bodyHeight() int {
+	return max(r.height-2, 0)
+}
+
+func (r *reader) postsHeight() int {
+	return max(r.bodyHeight()/3, 3)
+}
+
+func (r *reader) readingHeight() int {
+	return max(r.bodyHeight()-r.postsHeight()-1, 0)
+}
+
+func (r *reader) draw() {
+	var screen strings.Builder
+	screen.WriteString(clearScreen)
+
+	title := fmt.Sprintf(" gator - %s", clean(r.user.Name))
+	writeRow(&screen, 1, styleReverse+pad(title, r.width)+styleReset)
+
+	feedLines := r.feedsLines()
+	postLines := r.postsLines()
+	readingLines := r.readingLines()
+
+	for row := 0; row < r.bodyHeight(); row++ {
+		var line strings.Builder
+		line.WriteString(feedLines[row])
+		line.WriteString(styleDim + "│" + styleReset)
+
+		switch {
+		case row < r.postsHeight():
+			line.WriteString(postLines[row])
+		case row == r.postsHeight():
+			line.WriteString(styleDim + strings.Repeat("─", r.rightWidth()) + styleReset)
+		default:
+			line.WriteString(readingLines[row-r.postsHeight()-1])
+		}
+
+		writeRow(&screen, row+2, line.String())
+	}
+
+	status := r.status
+	if status == "" {
+		status = helpLine
+	}
+	writeRow(&screen, r.height, styleDim+truncate(clean(status), r.width)+styleReset)
+
+	os.Stdout.WriteString(screen.String())
+}
+
+func (r *reader) feedsLines() []string {
+	height := r.bodyHeight()
+	width := r.feedsWidth()
+
+	items := make([]string, len(r.feeds))
+	for i, feed := range r.feeds {
+		items[i] = " " + clean(feed.FeedName)
+	}
+	if len(items) == 0 {
+		items = append(items, " (no followed feeds)")
+	}
+
+	r.feedOffset = scrollOffset(r.feedIndex, r.feedOffset, height-1)
+	return listLines("Feeds", items, r.feedIndex, r.feedOffset, width, height, r.focus == feedsPane)
+}
+
+func (r *reader) postsLines() []string {
+	height := r.postsHeight()
+	width := r.rightWidth()
+
+	items := make([]string, len(r.posts))
+	for i, post := range r.posts {
+		marker := "●"
+		if post.Read {
+			marker = " "
+		}
+		items[i] = fmt.Sprintf(" %s %s  %s", marker, post.PublishedAt.Format("2006-01-02"), clean(post.Title))
+	}
+	if len(items) == 0 {
+		items = append(items, " (no posts)")
+	}
+
+	r.postOffset = scrollOffset(r.postIndex, r.postOffset, height-1)
+	return listLines("Posts", items, r.postIndex, r.postOffset, width, height, r.focus == postsPane)
+}
+
+func (r *reader) readingLines() []string {
+	height := r.readingHeight()
+	width := r.rightWidth()
+	lines := make([]string, height)
+
+	if r.openPost < 0 || r.openPost >= len(r.posts) {
+		for i := range lines {
+			lines[i] = pad("", width)
+		}
+		if height > 0 {
+			lines[0] = styleDim + pad(" Select a post and press Enter to read it.", width) + styleReset
+		}
+		return lines
+	}
+
+	if r.bodyLines == nil {
+		r.bodyLines = r.renderPost(width - 2)
+	}
+
+	for i := range lines {
+		index := r.bodyScroll + i
+		if index >= len(r.bodyLines) {
+			lines[i] = pad("", width)
+			continue
+		}
+		text := pad(" "+r.bodyLines[index], width)
+		if index == 0 {
+			text = styleBold + text + styleReset
+		}
+		lines[i] = text
+	}
+	return lines
+}
+
+func (r *reader) renderPost(width int) []string {
+	post := r.posts[r.openPost]
+
+	lines := wrap(clean(post.Title), width)
+	lines = append(lines, "Published: "+post.PublishedAt.Format("2006-01-02 15:04"))
+	lines = append(lines, "Link: "+clean(post.Url))
+	lines = append(lines, "")
+
+	if post.Description.Valid {
+		lines = append(lines, strings.Split(htmlrenderer.Render(post.Description.String, width), "\n")...)
+	}
+	return lines
+}
+
+// listLines renders a titled, scrollable list into exactly height lines of
+// the given width.
+func listLines(title string, items []string, selected, offset, width, height int, focused bool) []string {
+	lines := make([]string, height)
+	if height == 0 {
+		return lines
+	}
+
+	header := pad(" "+title, width)
+	if focused {
+		header = styleBold + header + styleReset
+	} else {
+		header = styleDim + header + styleReset
+	}
+	lines[0] = header
+
+	for i := 1; i < height; i++ {
+		index := offset + i - 1
+		if index >= len(items) {
+			lines[i] = pad("", width)
+			continue
+		}
+
+		text := pad(items[index], width)
+		if index == selected {
+			if focused {
+				text = styleReverse + text + styleReset
+			} else {
+				text = styleBold + text + styleReset
+			}
+		}
+		lines[i] = text
+	}
+	return lines
+}
+
+// scrollOffset returns the first visible index so that selected stays inside
+// a window of the given height.
+func scrollOffset(selected, offset, height int) int {
+	if height <= 0 {
+		return selected
+	}
+	if selected < offset {
+		return selected
+	}
+	if selected >= offset+height {
+		return selected - height + 1
+	}
+	return offset
+}
+
+func writeRow(screen *strings.Builder, row int, text string) {
+	fmt.Fprintf(screen, "\x1b[%d;1H%s", row, text)
+}
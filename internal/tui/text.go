@@ -0,0 +1,71 @@
+package tui
+
+import (
+	"strings"
+
+	htmlrenderer "github.com/alancorleto/gator/internal/html_renderer"
+)
+
+// clean makes text from a feed fit on one line of the screen: control
+// characters that could move the cursor or restyle the terminal are
+// removed, and runs of whitespace, line breaks included, become one space.
+func clean(text string) string {
+	return strings.Join(strings.Fields(htmlrenderer.StripControl(text)), " ")
+}
+
+// wrap breaks text into lines of at most width runes, preserving the line
+// breaks already present in the text.
+func wrap(text string, width int) []string {
+	if width <= 0 {
+		return nil
+	}
+
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		words := strings.Fields(paragraph)
+		if len(words) == 0 {
+			lines = append(lines, "")
+			continue
+		}
+
+		var line []rune
+		for _, word := range words {
+			wordRunes := []rune(word)
+			for len(wordRunes) > width {
+				if len(line) > 0 {
+					lines = append(lines, string(line))
+					line = nil
+				}
+				lines = append(lines, string(wordRunes[:width]))
+				wordRunes = wordRunes[width:]
+			}
+
+			switch {
+			case len(line) == 0:
+				line = wordRunes
+			case len(line)+1+len(wordRunes) <= width:
+				line = append(append(line, ' '), wordRunes...)
+			default:
+				lines = append(lines, string(line))
+				line = wordRunes
+			}
+		}
+		if len(line) > 0 {
+			lines = append(lines, string(line))
+		}
+	}
+	return lines
+}
+
+func truncate(text string, width int) string {
+	runes := []rune(text)
+	if len(runes) > width {
+		return string(runes[:max(width, 0)])
+	}
+	return text
+}
+
+func pad(text string, width int) string {
+	text = truncate(text, width)
+	return text + strings.Repeat(" ", max(width-len([]rune(text)), 0))
+}
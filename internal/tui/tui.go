@@ -0,0 +1,303 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	database "github.com/alancorleto/gator/internal/database"
+	"golang.org/x/term"
+)
+
+type pane int
+
+const (
+	feedsPane pane = iota
+	postsPane
+	readingPane
+)
+
+const postsPerFeed = 100
+
+type reader struct {
+	db   *database.Queries
+	user database.User
+
+	feeds      []database.GetFeedFollowsForUserRow
+	feedIndex  int
+	feedOffset int
+
+	posts      []database.GetPostsForUserFeedRow
+	postIndex  int
+	postOffset int
+
+	openPost   int
+	bodyLines  []string
+	bodyScroll int
+
+	focus  pane
+	status string
+
+	width  int
+	height int
+}
+
+// Run starts the full-screen reader for the given user and blocks until the
+// user quits or ctx is done.
+func Run(ctx context.Context, db *database.Queries, user database.User) error {
+	inFd := int(os.Stdin.Fd())
+	outFd := int(os.Stdout.Fd())
+	if !term.IsTerminal(inFd) || !term.IsTerminal(outFd) {
+		return fmt.Errorf("the terminal UI requires an interactive terminal")
+	}
+
+	r := &reader{
+		db:       db,
+		user:     user,
+		openPost: -1,
+	}
+	if err := r.loadFeeds(ctx); err != nil {
+		return err
+	}
+
+	oldState, err := term.MakeRaw(inFd)
+	if err != nil {
+		return fmt.Errorf("error setting terminal to raw mode: %v", err)
+	}
+	defer term.Restore(inFd, oldState)
+
+	fmt.Print(enterAltScreen + hideCursor)
+	defer fmt.Print(showCursor + exitAltScreen)
+
+	input := make([]byte, 16)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		r.width, r.height, err = term.GetSize(outFd)
+		if err != nil {
+			return fmt.Errorf("error reading terminal size: %v", err)
+		}
+		r.draw()
+
+		n, err := os.Stdin.Read(input)
+		if err != nil {
+			return fmt.Errorf("error reading input: %v", err)
+		}
+		if quit := r.handleKey(ctx, parseKey(input[:n])); quit {
+			return nil
+		}
+	}
+}
+
+func (r *reader) loadFeeds(ctx context.Context) error {
+	feeds, err := r.db.GetFeedFollowsForUser(ctx, r.user.ID)
+	if err != nil {
+		return fmt.Errorf("error getting feeds for user %s: %v", r.user.Name, err)
+	}
+	r.feeds = feeds
+	if r.feedIndex >= len(r.feeds) {
+		r.feedIndex = max(len(r.feeds)-1, 0)
+	}
+	return r.loadPosts(ctx)
+}
+
+func (r *reader) loadPosts(ctx context.Context) error {
+	r.posts = nil
+	r.postIndex = 0
+	r.postOffset = 0
+	r.closePost()
+
+	if len(r.feeds) == 0 {
+		return nil
+	}
+
+	posts, err := r.db.GetPostsForUserFeed(
+		ctx,
+		database.GetPostsForUserFeedParams{
+			UserID: r.user.ID,
+			FeedID: r.feeds[r.feedIndex].FeedID,
+			Limit:  postsPerFeed,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("error getting posts for feed %s: %v", r.feeds[r.feedIndex].FeedName, err)
+	}
+	r.posts = posts
+	return nil
+}
+
+func (r *reader) openSelectedPost(ctx context.Context) {
+	if len(r.posts) == 0 {
+		return
+	}
+
+	post := &r.posts[r.postIndex]
+	r.openPost = r.postIndex
+	r.bodyScroll = 0
+	r.bodyLines = nil
+
+	if !post.Read {
+		err := r.db.MarkPostRead(
+			ctx,
+			database.MarkPostReadParams{
+				UserID: r.user.ID,
+				PostID: post.ID,
+				ReadAt: time.Now(),
+			},
+		)
+		if err != nil {
+			r.status = fmt.Sprintf("error marking post as read: %v", err)
+		} else {
+			post.Read = true
+		}
+	}
+}
+
+func (r *reader) closePost() {
+	r.openPost = -1
+	r.bodyLines = nil
+	r.bodyScroll = 0
+}
+
+// handleKey applies a key press to the reader and reports whether the user
+// asked to quit.
+func (r *reader) handleKey(ctx context.Context, key string) bool {
+	r.status = ""
+
+	switch key {
+	case "q", "ctrl-c":
+		return true
+	case "tab":
+		r.focus = (r.focus + 1) % 3
+		return false
+	case "backtab":
+		r.focus = (r.focus + 2) % 3
+		return false
+	case "r":
+		if err := r.loadFeeds(ctx); err != nil {
+			r.status = err.Error()
+		} else {
+			r.status = "Reloaded feeds."
+		}
+		return false
+	}
+
+	switch r.focus {
+	case feedsPane:
+		r.handleFeedsKey(ctx, key)
+	case postsPane:
+		r.handlePostsKey(ctx, key)
+	case readingPane:
+		r.handleReadingKey(ctx, key)
+	}
+	return false
+}
+
+func (r *reader) handleFeedsKey(ctx context.Context, key string) {
+	previous := r.feedIndex
+	switch key {
+	case "up", "k":
+		r.feedIndex = max(r.feedIndex-1, 0)
+	case "down", "j":
+		r.feedIndex = min(r.feedIndex+1, max(len(r.feeds)-1, 0))
+	case "home", "g":
+		r.feedIndex = 0
+	case "end", "G":
+		r.feedIndex = max(len(r.feeds)-1, 0)
+	case "enter", "right", "l":
+		r.focus = postsPane
+	}
+
+	if r.feedIndex != previous {
+		if err := r.loadPosts(ctx); err != nil {
+			r.status = err.Error()
+		}
+	}
+}
+
+func (r *reader) handlePostsKey(ctx context.Context, key string) {
+	switch key {
+	case "up", "k":
+		r.postIndex = max(r.postIndex-1, 0)
+	case "down", "j":
+		r.postIndex = min(r.postIndex+1, max(len(r.posts)-1, 0))
+	case "home", "g":
+		r.postIndex = 0
+	case "end", "G":
+		r.postIndex = max(len(r.posts)-1, 0)
+	case "enter", "right", "l":
+		r.openSelectedPost(ctx)
+		if r.openPost >= 0 {
+			r.focus = readingPane
+		}
+	case "left", "h", "esc":
+		r.focus = feedsPane
+	}
+}
+
+func (r *reader) handleReadingKey(ctx context.Context, key string) {
+	page := max(r.readingHeight()-1, 1)
+	switch key {
+	case "up", "k":
+		r.bodyScroll--
+	case "down", "j":
+		r.bodyScroll++
+	case "pgup", "b":
+		r.bodyScroll -= page
+	case "pgdown", "space":
+		r.bodyScroll += page
+	case "home", "g":
+		r.bodyScroll = 0
+	case "end", "G":
+		r.bodyScroll = len(r.bodyLines)
+	case "n":
+		if r.postIndex < len(r.posts)-1 {
+			r.postIndex++
+			r.openSelectedPost(ctx)
+		}
+	case "p":
+		if r.postIndex > 0 {
+			r.postIndex--
+			r.openSelectedPost(ctx)
+		}
+	case "left", "h", "esc":
+		r.focus = postsPane
+	}
+	r.bodyScroll = max(min(r.bodyScroll, len(r.bodyLines)-r.readingHeight()), 0)
+}
+
+func parseKey(input []byte) string {
+	switch string(input) {
+	case "\x1b[A", "\x1bOA":
+		return "up"
+	case "\x1b[B", "\x1bOB":
+		return "down"
+	case "\x1b[C", "\x1bOC":
+		return "right"
+	case "\x1b[D", "\x1bOD":
+		return "left"
+	case "\x1b[5~":
+		return "pgup"
+	case "\x1b[6~":
+		return "pgdown"
+	case "\x1b[H", "\x1b[1~", "\x1bOH":
+		return "home"
+	case "\x1b[F", "\x1b[4~", "\x1bOF":
+		return "end"
+	case "\x1b[Z":
+		return "backtab"
+	case "\x1b":
+		return "esc"
+	case "\t":
+		return "tab"
+	case "\r", "\n":
+		return "enter"
+	case " ":
+		return "space"
+	case "\x03":
+		return "ctrl-c"
+	}
+	return string(input)
+}
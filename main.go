@@ -1,41 +1,96 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	_ "github.com/lib/pq"
 
 	commands "github.com/alancorleto/gator/internal/commands"
 	config "github.com/alancorleto/gator/internal/config"
 	database "github.com/alancorleto/gator/internal/database"
+	feedfetcher "github.com/alancorleto/gator/internal/feed_fetcher"
+	logging "github.com/alancorleto/gator/internal/logging"
 	state "github.com/alancorleto/gator/internal/state"
+	tracing "github.com/alancorleto/gator/internal/tracing"
 )
 
+// tracingFlushTimeout bounds the export of the last spans on exit.
+const tracingFlushTimeout = 5 * time.Second
+
 func main() {
 	cfg, err := config.Read()
 	if err != nil {
-		fmt.Println("Error reading config:", err)
+		fmt.Fprintln(os.Stderr, "Error reading config:", err)
 		os.Exit(1)
 	}
 
 	if len(os.Args) < 2 {
-		fmt.Println("No command provided.")
+		fmt.Fprintln(os.Stderr, "No command provided.")
 		os.Exit(1)
 	}
 
 	db, err := sql.Open("postgres", cfg.DbUrl)
 	if err != nil {
-		fmt.Println("Error connecting to database:", err)
+		fmt.Fprintln(os.Stderr, "Error connecting to database:", err)
 		os.Exit(1)
 	}
 	defer db.Close()
-	dbQueries := database.New(db)
+	dbQueries := database.New(tracing.WrapDB(db))
+
+	fetcher, err := feedfetcher.NewFetcher(feedfetcher.Options{
+		ConnectTimeout: time.Duration(cfg.Fetch.ConnectTimeout),
+		Timeout:        time.Duration(cfg.Fetch.Timeout),
+		MaxBodyBytes:   cfg.Fetch.MaxBodyBytes,
+		UserAgent:      cfg.Fetch.UserAgent,
+		ContactURL:     cfg.Fetch.ContactURL,
+		StrictXML:      cfg.Fetch.StrictXML,
+		AllowedHosts:   cfg.Fetch.AllowedHosts,
+		Limits: feedfetcher.Limits{
+			MaxDepth:      cfg.Fetch.MaxDepth,
+			MaxItems:      cfg.Fetch.MaxItems,
+			MaxFieldBytes: cfg.Fetch.MaxFieldBytes,
+		},
+		HostInterval:    time.Duration(cfg.Fetch.HostInterval),
+		HostBurst:       cfg.Fetch.HostBurst,
+		HostConcurrency: cfg.Fetch.HostConcurrency,
+		MaxRetryAfter:   time.Duration(cfg.Fetch.MaxRetryAfter),
+		MaxRetries:      cfg.Fetch.MaxRetries,
+		RetryBudget:     time.Duration(cfg.Fetch.RetryBudget),
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error reading config:", err)
+		os.Exit(1)
+	}
+
+	logger, err := logging.New(os.Stderr, cfg.Log.Level, cfg.Log.Format)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error setting up logging:", err)
+		os.Exit(1)
+	}
+	slog.SetDefault(logger)
+
+	stopTracing, err := tracing.Start(context.Background(), tracing.Options{
+		Exporter: cfg.Tracing.Exporter,
+		Endpoint: cfg.Tracing.Endpoint,
+		Logger:   logger,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error setting up tracing:", err)
+		os.Exit(1)
+	}
 
 	state := &state.State{
-		Config: cfg,
-		Db:     dbQueries,
+		Config:  cfg,
+		Db:      dbQueries,
+		Fetcher: fetcher,
+		Logger:  logger,
 	}
 
 	cmd := commands.Command{
@@ -43,10 +98,27 @@ func main() {
 		Arguments: os.Args[2:],
 	}
 
+	// The first SIGINT or SIGTERM cancels ctx so that the command can wind
+	// down cleanly; a second one ends the process right away.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
 	cmds := commands.InitializeCommands()
-	err = cmds.Run(state, cmd)
+	err = cmds.Run(ctx, state, cmd)
+
+	// Spans still buffered are exported before exiting.
+	flushCtx, cancel := context.WithTimeout(context.Background(), tracingFlushTimeout)
+	defer cancel()
+	if err := stopTracing(flushCtx); err != nil {
+		logger.Warn("failed to export traces", "error", err)
+	}
+
 	if err != nil {
-		fmt.Println("Error executing command:", err)
+		fmt.Fprintln(os.Stderr, "Error executing command:", err)
 		os.Exit(1)
 	}
 }
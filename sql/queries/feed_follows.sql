@@ -34,4 +34,15 @@ DELETE FROM feed_follows;
 
 -- name: DeleteFeedFollow :exec
 DELETE FROM feed_follows
-WHERE user_id = $1 AND feed_id = $2;
\ No newline at end of file
+WHERE user_id = $1 AND feed_id = $2;
+
+-- name: CountFeedFollowers :one
+SELECT COUNT(*) FROM feed_follows
+WHERE feed_id = $1;
+
+-- name: MoveFeedFollows :exec
+INSERT INTO feed_follows (id, created_at, updated_at, user_id, feed_id)
+SELECT gen_random_uuid(), created_at, NOW(), user_id, sqlc.arg(new_feed_id)::uuid
+FROM feed_follows
+WHERE feed_id = sqlc.arg(old_feed_id)
+ON CONFLICT (user_id, feed_id) DO NOTHING;
\ No newline at end of file
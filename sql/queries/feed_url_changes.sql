@@ -0,0 +1,22 @@
+-- name: CreateFeedURLChange :one
+INSERT INTO feed_url_changes (id, feed_id, old_url, new_url, status_code, merged, changed_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+)
+RETURNING *;
+
+-- name: GetFeedURLChanges :many
+SELECT *
+FROM feed_url_changes
+ORDER BY changed_at;
+
+-- name: MoveFeedURLChanges :exec
+UPDATE feed_url_changes
+SET feed_id = sqlc.arg(new_feed_id)
+WHERE feed_id = sqlc.arg(old_feed_id);
\ No newline at end of file
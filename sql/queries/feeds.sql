@@ -14,9 +14,9 @@ RETURNING *;
 DELETE FROM feeds;
 
 -- name: GetFeeds :many
-SELECT feeds.name, feeds.url, users.name AS user_name
+SELECT feeds.id, feeds.name, feeds.url, users.name AS user_name, feeds.last_fetch_error, feeds.next_fetch_at
 FROM feeds
-INNER JOIN users
+LEFT JOIN users
 ON feeds.user_id = users.id;
 
 -- name: GetFeedByURL :one
@@ -30,8 +30,87 @@ SET last_fetched_at = NOW(),
     updated_at = NOW()
 WHERE id = $1;
 
--- name: GetNextFeedToFetch :one
-SELECT *
+-- name: ClaimNextFeedToFetch :one
+UPDATE feeds
+SET last_fetched_at = NOW(),
+    updated_at = NOW()
+WHERE id = (
+    SELECT id
+    FROM feeds
+    WHERE (next_fetch_at IS NULL OR next_fetch_at <= NOW())
+    AND (sqlc.narg(fetched_before)::timestamp IS NULL OR last_fetched_at IS NULL OR last_fetched_at < sqlc.narg(fetched_before))
+    ORDER BY last_fetched_at ASC NULLS FIRST
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: GetDatabaseTime :one
+SELECT NOW()::timestamp AS now;
+
+-- name: DeleteFeed :exec
+DELETE FROM feeds
+WHERE id = $1;
+
+-- name: UpdateFeedName :one
+UPDATE feeds
+SET name = $2,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: UpdateFeedURL :one
+UPDATE feeds
+SET url = $2,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: GetFeedsOwnedByUser :many
+SELECT feeds.*, (
+    SELECT COUNT(*) FROM feed_follows
+    WHERE feed_follows.feed_id = feeds.id AND feed_follows.user_id <> feeds.user_id
+) AS other_followers
+FROM feeds
+WHERE feeds.user_id = $1
+ORDER BY feeds.name;
+
+-- name: TransferFeeds :exec
+UPDATE feeds
+SET user_id = sqlc.arg(new_user_id),
+    updated_at = NOW()
+WHERE user_id = sqlc.arg(old_user_id);
+
+-- name: UpdateFeedOwner :one
+UPDATE feeds
+SET user_id = $2,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteUnsharedFeedsForUser :execrows
+DELETE FROM feeds
+WHERE user_id = $1
+AND NOT EXISTS (
+    SELECT 1 FROM feed_follows
+    WHERE feed_follows.feed_id = feeds.id AND feed_follows.user_id <> feeds.user_id
+);
+
+-- name: SetFeedFetchResult :exec
+UPDATE feeds
+SET last_fetch_error = $2,
+    next_fetch_at = $3
+WHERE id = $1;
+
+-- name: GetFeedQueueStats :one
+SELECT
+    COUNT(*) FILTER (WHERE next_fetch_at IS NULL OR next_fetch_at <= NOW()) AS due,
+    COUNT(*) FILTER (WHERE next_fetch_at > NOW()) AS deferred,
+    COUNT(*) FILTER (WHERE last_fetched_at IS NULL) AS never_fetched
+FROM feeds;
+
+-- name: GetFeedsWithFetchErrors :many
+SELECT id, name, url, last_fetched_at, last_fetch_error, next_fetch_at
 FROM feeds
-ORDER BY last_fetched_at ASC NULLS FIRST
-LIMIT 1;
+WHERE last_fetch_error IS NOT NULL
+ORDER BY name;
\ No newline at end of file
@@ -7,7 +7,8 @@ INSERT INTO posts (
     url,
     description,
     published_at,
-    feed_id
+    feed_id,
+    original_description
 )
 VALUES (
     $1,
@@ -17,7 +18,8 @@ VALUES (
     $5,
     $6,
     $7,
-    $8
+    $8,
+    $9
 )
 RETURNING *;
 
@@ -25,5 +27,32 @@ RETURNING *;
 SELECT posts.* FROM posts
 INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id
 WHERE feed_follows.user_id = $1
-ORDER BY posts.published_at DESC
-LIMIT $2;
\ No newline at end of file
+ORDER BY posts.published_at DESC, posts.id DESC
+LIMIT $2;
+
+-- name: GetPostsForUserFeed :many
+SELECT posts.*, post_reads.read_at IS NOT NULL AS read
+FROM posts
+LEFT JOIN post_reads ON post_reads.post_id = posts.id AND post_reads.user_id = $1
+WHERE posts.feed_id = $2
+ORDER BY posts.published_at DESC, posts.id DESC
+LIMIT $3;
+
+-- name: GetPostsPageForUser :many
+SELECT posts.*, post_reads.read_at IS NOT NULL AS read
+FROM posts
+INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id
+LEFT JOIN post_reads ON post_reads.post_id = posts.id AND post_reads.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = $1
+ORDER BY posts.published_at DESC, posts.id DESC
+LIMIT $2 OFFSET $3;
+
+-- name: CountPostsForFeed :one
+SELECT COUNT(*) FROM posts
+WHERE feed_id = $1;
+
+-- name: MovePosts :exec
+UPDATE posts
+SET feed_id = sqlc.arg(new_feed_id),
+    updated_at = NOW()
+WHERE feed_id = sqlc.arg(old_feed_id);
\ No newline at end of file
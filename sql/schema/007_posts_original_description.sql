@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE posts
+ADD COLUMN original_description TEXT;
+
+-- +goose Down
+ALTER TABLE posts
+DROP COLUMN original_description;
\ No newline at end of file
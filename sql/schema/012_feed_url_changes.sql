@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE feed_url_changes(
+    id UUID PRIMARY KEY,
+    feed_id UUID NOT NULL,
+    old_url TEXT NOT NULL,
+    new_url TEXT NOT NULL,
+    status_code INTEGER NOT NULL,
+    merged BOOLEAN NOT NULL,
+    changed_at TIMESTAMP NOT NULL,
+    FOREIGN KEY(feed_id) REFERENCES feeds(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE feed_url_changes;
\ No newline at end of file